@@ -0,0 +1,363 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+)
+
+// messageAggregate collects command messages (edits, deletes, reactions, pins)
+// and thread replies that apply to other messages in a channel.
+// Edits and deletes are only applied if they are from the sender of the
+// original message.
+// The aggregate for a channel is cached and added to as messages arrive, so
+// it's locked, and the slices it returns are copies.
+type messageAggregate struct {
+	mtx sync.RWMutex
+
+	byID    map[string]*api.Message
+	edits   map[string]*api.Message
+	deletes map[string]*api.Message
+	replies map[string][]*api.Message
+	// reactions by message ID, in the order they were first added.
+	reactions map[string][]*reaction
+	// expires is when messages disappear, from the channel retention when
+	// they were sent.
+	expires map[string]int64
+	// pins in the order they were pinned.
+	pins []*pin
+
+	// index is the remote index of the last message added, and retention the
+	// channel retention at that point, so we can add messages after.
+	index     int64
+	retention int64
+}
+
+// pin is a pinned message and who pinned it.
+type pin struct {
+	id        string
+	sender    keys.ID
+	timestamp int64
+}
+
+// reaction is an emoji and who reacted with it.
+type reaction struct {
+	emoji   string
+	senders []keys.ID
+}
+
+func newMessageAggregate() *messageAggregate {
+	return &messageAggregate{
+		byID:    map[string]*api.Message{},
+		edits:   map[string]*api.Message{},
+		deletes: map[string]*api.Message{},
+		replies: map[string][]*api.Message{},
+
+		reactions: map[string][]*reaction{},
+		expires:   map[string]int64{},
+	}
+}
+
+// aggregateMessages returns the aggregate for messages, which should be
+// ordered by remote index.
+func aggregateMessages(msgs []*api.Message) *messageAggregate {
+	agg := newMessageAggregate()
+	agg.add(msgs)
+	return agg
+}
+
+// add messages, which should be ordered by remote index (and after any
+// messages already added).
+func (agg *messageAggregate) add(msgs []*api.Message) {
+	agg.mtx.Lock()
+	defer agg.mtx.Unlock()
+	for _, msg := range msgs {
+		agg.byID[msg.ID] = msg
+	}
+	for _, msg := range msgs {
+		if msg.RemoteIndex > agg.index {
+			agg.index = msg.RemoteIndex
+		}
+		if msg.Command != nil && msg.Command.ChannelRetention != nil {
+			agg.retention = msg.Command.ChannelRetention.TTL
+		} else if agg.retention > 0 && expires(msg) {
+			agg.expires[msg.ID] = messageTime(msg) + agg.retention
+		}
+		if msg.Parent != "" {
+			agg.replies[msg.Parent] = append(agg.replies[msg.Parent], msg)
+		}
+		if msg.Command == nil {
+			continue
+		}
+		if edit := msg.Command.MessageEdit; edit != nil {
+			if !agg.isSender(edit.ID, msg) {
+				logger.Warningf("Ignoring edit of %s from %s (not the sender)", edit.ID, msg.Sender)
+				continue
+			}
+			agg.edits[edit.ID] = msg
+		}
+		if del := msg.Command.MessageDelete; del != nil {
+			if !agg.isSender(del.ID, msg) {
+				logger.Warningf("Ignoring delete of %s from %s (not the sender)", del.ID, msg.Sender)
+				continue
+			}
+			agg.deletes[del.ID] = msg
+		}
+		if react := msg.Command.MessageReaction; react != nil {
+			agg.react(react, msg.Sender)
+		}
+		if p := msg.Command.MessagePin; p != nil {
+			agg.pin(p, msg)
+		}
+	}
+}
+
+// aggregateCache keeps channel aggregates in memory, so we only need to add
+// messages since we last looked.
+type aggregateCache struct {
+	sync.Mutex
+	channels map[keys.ID]*messageAggregate
+}
+
+func newAggregateCache() *aggregateCache {
+	return &aggregateCache{channels: map[keys.ID]*messageAggregate{}}
+}
+
+// remove the aggregate for a channel, if messages were removed (or we left).
+func (c *aggregateCache) remove(channel keys.ID) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.channels, channel)
+}
+
+// clear the cache, on lock.
+func (c *aggregateCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.channels = map[keys.ID]*messageAggregate{}
+}
+
+// message returns the message with id, or nil if not found.
+func (a *messageAggregate) message(id string) *api.Message {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.byID[id]
+}
+
+// deleted returns true if the message with id was deleted.
+func (a *messageAggregate) deleted(id string) bool {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	_, ok := a.deletes[id]
+	return ok
+}
+
+// expired returns true if the message with id has expired at now.
+func (a *messageAggregate) expired(id string, now int64) bool {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.isExpired(id, now)
+}
+
+func (a *messageAggregate) isExpired(id string, now int64) bool {
+	exp, ok := a.expires[id]
+	return ok && exp <= now
+}
+
+// unexpired returns messages that haven't expired at now.
+func (a *messageAggregate) unexpired(msgs []*api.Message, now int64) []*api.Message {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	out := make([]*api.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if !a.isExpired(msg.ID, now) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// isSender returns true if cmd is from the sender of the message with id.
+func (a *messageAggregate) isSender(id string, cmd *api.Message) bool {
+	orig, ok := a.byID[id]
+	if !ok {
+		return false
+	}
+	return orig.Sender == cmd.Sender
+}
+
+// visible returns false for command messages that are shown as part of
+// another message, and for replies, which are shown in the thread. It only
+// depends on the message itself, so it doesn't need an aggregate.
+func visible(msg *api.Message) bool {
+	if msg.Parent != "" {
+		return false
+	}
+	if msg.Command == nil {
+		return true
+	}
+	if msg.Command.MessageEdit != nil || msg.Command.MessageDelete != nil || msg.Command.MessageReaction != nil || msg.Command.MessagePin != nil {
+		return false
+	}
+	return true
+}
+
+// react adds or removes a reaction from sender.
+func (a *messageAggregate) react(react *api.MessageReaction, sender keys.ID) {
+	if _, ok := a.byID[react.ID]; !ok {
+		return
+	}
+	rs := a.reactions[react.ID]
+	var r *reaction
+	for _, existing := range rs {
+		if existing.emoji == react.Emoji {
+			r = existing
+			break
+		}
+	}
+	if react.Remove {
+		if r == nil {
+			return
+		}
+		senders := make([]keys.ID, 0, len(r.senders))
+		for _, s := range r.senders {
+			if s != sender {
+				senders = append(senders, s)
+			}
+		}
+		r.senders = senders
+		if len(senders) == 0 {
+			out := make([]*reaction, 0, len(rs))
+			for _, existing := range rs {
+				if existing != r {
+					out = append(out, existing)
+				}
+			}
+			a.reactions[react.ID] = out
+		}
+		return
+	}
+	if r == nil {
+		r = &reaction{emoji: react.Emoji}
+		a.reactions[react.ID] = append(rs, r)
+	}
+	for _, s := range r.senders {
+		if s == sender {
+			return
+		}
+	}
+	r.senders = append(r.senders, sender)
+}
+
+// pin adds or removes a pin. Any member can pin or unpin.
+func (a *messageAggregate) pin(p *api.MessagePin, cmd *api.Message) {
+	if _, ok := a.byID[p.ID]; !ok {
+		return
+	}
+	if !p.Remove {
+		for _, existing := range a.pins {
+			if existing.id == p.ID {
+				// Already pinned, keep who pinned it first.
+				return
+			}
+		}
+		a.pins = append(a.pins, &pin{id: p.ID, sender: cmd.Sender, timestamp: cmd.Timestamp})
+		return
+	}
+	pins := make([]*pin, 0, len(a.pins))
+	for _, existing := range a.pins {
+		if existing.id != p.ID {
+			pins = append(pins, existing)
+		}
+	}
+	a.pins = pins
+}
+
+// pinned returns the pin for a message, or nil if not pinned (or deleted).
+func (a *messageAggregate) pinned(id string) *pin {
+	if a == nil {
+		return nil
+	}
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return a.findPin(id)
+}
+
+func (a *messageAggregate) findPin(id string) *pin {
+	if _, ok := a.deletes[id]; ok {
+		return nil
+	}
+	for _, p := range a.pins {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// pinnedMessages returns pinned messages, most recently pinned first.
+func (a *messageAggregate) pinnedMessages() []*api.Message {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	out := []*api.Message{}
+	for i := len(a.pins) - 1; i >= 0; i-- {
+		if a.findPin(a.pins[i].id) != nil {
+			out = append(out, a.byID[a.pins[i].id])
+		}
+	}
+	return out
+}
+
+// messageReactions returns reactions to a message.
+func (a *messageAggregate) messageReactions(id string) []*reaction {
+	if a == nil {
+		return nil
+	}
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	rs := a.reactions[id]
+	out := make([]*reaction, 0, len(rs))
+	for _, r := range rs {
+		out = append(out, &reaction{emoji: r.emoji, senders: append([]keys.ID{}, r.senders...)})
+	}
+	return out
+}
+
+// thread returns replies to a message.
+func (a *messageAggregate) thread(id string) []*api.Message {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	return append([]*api.Message{}, a.replies[id]...)
+}
+
+// apply updates the RPC message with edits, deletes, thread info, pins or
+// expiry. Replies that expired at now, or were deleted, aren't counted.
+func (a *messageAggregate) apply(m *Message, now int64) {
+	if a == nil {
+		return
+	}
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+	m.ExpiresAt = a.expires[m.ID]
+	for _, reply := range a.replies[m.ID] {
+		if _, ok := a.deletes[reply.ID]; ok || a.isExpired(reply.ID, now) {
+			continue
+		}
+		m.ReplyCount++
+		m.LastReplyAt = reply.Timestamp
+	}
+	if _, ok := a.deletes[m.ID]; ok {
+		m.Text = []string{}
+		m.Deleted = true
+		return
+	}
+	if p := a.findPin(m.ID); p != nil {
+		m.Pinned = true
+		m.PinnedAt = p.timestamp
+	}
+	if edit, ok := a.edits[m.ID]; ok {
+		m.Text = []string{edit.Command.MessageEdit.Text}
+		m.Edited = true
+	}
+}
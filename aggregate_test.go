@@ -0,0 +1,235 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateEditDelete(t *testing.T) {
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "hi", RemoteIndex: 1},
+		{ID: "m2", Sender: "bob", Text: "hey", RemoteIndex: 2},
+		{ID: "m3", Sender: "alice", RemoteIndex: 3, Command: &api.MessageCommand{
+			MessageEdit: &api.MessageEdit{ID: "m1", Text: "hello"},
+		}},
+		// Bob can't edit or delete alice's message
+		{ID: "m4", Sender: "bob", RemoteIndex: 4, Command: &api.MessageCommand{
+			MessageEdit: &api.MessageEdit{ID: "m1", Text: "bye"},
+		}},
+		{ID: "m5", Sender: "bob", RemoteIndex: 5, Command: &api.MessageCommand{
+			MessageDelete: &api.MessageDelete{ID: "m1"},
+		}},
+		{ID: "m6", Sender: "bob", RemoteIndex: 6, Command: &api.MessageCommand{
+			MessageDelete: &api.MessageDelete{ID: "m2"},
+		}},
+	}
+	agg := aggregateMessages(msgs)
+
+	visible := visibleMessages(msgs)
+	require.Equal(t, 2, len(visible))
+	require.Equal(t, "m1", visible[0].ID)
+	require.Equal(t, "m2", visible[1].ID)
+
+	m1 := &Message{ID: "m1", Text: []string{"hi"}}
+	agg.apply(m1, 0)
+	require.Equal(t, []string{"hello"}, m1.Text)
+	require.True(t, m1.Edited)
+	require.False(t, m1.Deleted)
+
+	m2 := &Message{ID: "m2", Text: []string{"hey"}}
+	agg.apply(m2, 0)
+	require.Equal(t, []string{}, m2.Text)
+	require.True(t, m2.Deleted)
+}
+
+func TestAggregateThread(t *testing.T) {
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "hi", RemoteIndex: 1, Timestamp: 1000},
+		{ID: "m2", Sender: "bob", Text: "reply1", Parent: "m1", RemoteIndex: 2, Timestamp: 2000},
+		{ID: "m3", Sender: "alice", Text: "hey", RemoteIndex: 3, Timestamp: 3000},
+		{ID: "m4", Sender: "alice", Text: "reply2", Parent: "m1", RemoteIndex: 4, Timestamp: 4000},
+	}
+	agg := aggregateMessages(msgs)
+
+	visible := visibleMessages(msgs)
+	require.Equal(t, 2, len(visible))
+	require.Equal(t, "m1", visible[0].ID)
+	require.Equal(t, "m3", visible[1].ID)
+
+	replies := agg.thread("m1")
+	require.Equal(t, 2, len(replies))
+	require.Equal(t, "m2", replies[0].ID)
+	require.Equal(t, "m4", replies[1].ID)
+	require.Equal(t, 0, len(agg.thread("m3")))
+
+	m1 := &Message{ID: "m1", Text: []string{"hi"}}
+	agg.apply(m1, 0)
+	require.Equal(t, int32(2), m1.ReplyCount)
+	require.Equal(t, int64(4000), m1.LastReplyAt)
+
+	m3 := &Message{ID: "m3", Text: []string{"hey"}}
+	agg.apply(m3, 0)
+	require.Equal(t, int32(0), m3.ReplyCount)
+}
+
+func TestAggregateThreadExpiredDeleted(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	reply := func(id string, sender keys.ID, ts int64) *api.Message {
+		return &api.Message{ID: id, Sender: sender, Text: id, Parent: "m1", Timestamp: ts, RemoteTimestamp: ts}
+	}
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "hi", Timestamp: 1000, RemoteTimestamp: 1000},
+		{ID: "m2", Sender: "alice", Timestamp: 2000, RemoteTimestamp: 2000, Command: &api.MessageCommand{
+			ChannelRetention: &api.ChannelRetention{TTL: hour},
+		}},
+		reply("m3", "bob", 3000),
+		{ID: "m4", Sender: "alice", Timestamp: 4000, RemoteTimestamp: 4000, Command: &api.MessageCommand{
+			ChannelRetention: &api.ChannelRetention{TTL: 0},
+		}},
+		reply("m5", "bob", 5000),
+		reply("m6", "bob", 6000),
+		{ID: "m7", Sender: "bob", Timestamp: 7000, RemoteTimestamp: 7000, Command: &api.MessageCommand{
+			MessageDelete: &api.MessageDelete{ID: "m6"},
+		}},
+	}
+	for i, msg := range msgs {
+		msg.RemoteIndex = int64(i + 1)
+	}
+	agg := aggregateMessages(msgs)
+
+	// The deleted reply isn't counted
+	m1 := &Message{ID: "m1"}
+	agg.apply(m1, 3000)
+	require.Equal(t, int32(2), m1.ReplyCount)
+	require.Equal(t, int64(5000), m1.LastReplyAt)
+
+	// Nor the expired reply
+	m1 = &Message{ID: "m1"}
+	agg.apply(m1, 3000+hour)
+	require.Equal(t, int32(1), m1.ReplyCount)
+	require.Equal(t, int64(5000), m1.LastReplyAt)
+}
+
+func TestAggregateReactions(t *testing.T) {
+	react := func(id string, sender keys.ID, ridx int64, emoji string, remove bool) *api.Message {
+		return &api.Message{ID: id, Sender: sender, RemoteIndex: ridx, Command: &api.MessageCommand{
+			MessageReaction: &api.MessageReaction{ID: "m1", Emoji: emoji, Remove: remove},
+		}}
+	}
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "hi", RemoteIndex: 1},
+		react("r1", "bob", 2, "👍", false),
+		react("r2", "alice", 3, "👍", false),
+		react("r3", "bob", 4, "🎉", false),
+		// Duplicate is ignored
+		react("r4", "bob", 5, "👍", false),
+		react("r5", "bob", 6, "🎉", true),
+		// Remove without add is ignored
+		react("r6", "charlie", 7, "👍", true),
+	}
+	agg := aggregateMessages(msgs)
+
+	visible := visibleMessages(msgs)
+	require.Equal(t, 1, len(visible))
+
+	reactions := agg.messageReactions("m1")
+	require.Equal(t, 1, len(reactions))
+	require.Equal(t, "👍", reactions[0].emoji)
+	require.Equal(t, []keys.ID{"bob", "alice"}, reactions[0].senders)
+
+	var nilAgg *messageAggregate
+	require.Nil(t, nilAgg.messageReactions("m1"))
+}
+
+func TestAggregatePins(t *testing.T) {
+	pin := func(id string, sender keys.ID, ridx int64, target string, remove bool) *api.Message {
+		return &api.Message{ID: id, Sender: sender, RemoteIndex: ridx, Timestamp: ridx * 1000, Command: &api.MessageCommand{
+			MessagePin: &api.MessagePin{ID: target, Remove: remove},
+		}}
+	}
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "runbook", RemoteIndex: 1},
+		{ID: "m2", Sender: "alice", Text: "link", RemoteIndex: 2},
+		{ID: "m3", Sender: "alice", Text: "oops", RemoteIndex: 3},
+		pin("p1", "bob", 4, "m1", false),
+		pin("p2", "alice", 5, "m2", false),
+		// Already pinned is ignored
+		pin("p3", "charlie", 6, "m1", false),
+		pin("p4", "bob", 7, "m3", false),
+		// Anyone can unpin
+		pin("p5", "charlie", 8, "m2", true),
+		// Unknown message is ignored
+		pin("p6", "bob", 9, "m4", false),
+		{ID: "d1", Sender: "alice", RemoteIndex: 10, Command: &api.MessageCommand{
+			MessageDelete: &api.MessageDelete{ID: "m3"},
+		}},
+	}
+	agg := aggregateMessages(msgs)
+
+	visible := visibleMessages(msgs)
+	require.Equal(t, 3, len(visible))
+
+	// Deleted messages aren't pinned
+	ids := []string{}
+	for _, msg := range agg.pinnedMessages() {
+		ids = append(ids, msg.ID)
+	}
+	require.Equal(t, []string{"m1"}, ids)
+
+	p := agg.pinned("m1")
+	require.NotNil(t, p)
+	require.Equal(t, keys.ID("bob"), p.sender)
+	require.Nil(t, agg.pinned("m2"))
+
+	m1 := &Message{ID: "m1"}
+	agg.apply(m1, 0)
+	require.True(t, m1.Pinned)
+	require.Equal(t, int64(4000), m1.PinnedAt)
+
+	var nilAgg *messageAggregate
+	require.Nil(t, nilAgg.pinned("m1"))
+}
+
+func TestAggregateAdd(t *testing.T) {
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "hi", RemoteIndex: 1},
+		{ID: "m2", Sender: "bob", RemoteIndex: 2, Command: &api.MessageCommand{
+			MessageReaction: &api.MessageReaction{ID: "m1", Emoji: "👍"},
+		}},
+	}
+	agg := aggregateMessages(msgs)
+	require.Equal(t, int64(2), agg.index)
+
+	reactions := agg.messageReactions("m1")
+
+	agg.add([]*api.Message{
+		{ID: "m3", Sender: "alice", RemoteIndex: 3, Command: &api.MessageCommand{
+			MessageReaction: &api.MessageReaction{ID: "m1", Emoji: "👍"},
+		}},
+		{ID: "m4", Sender: "alice", RemoteIndex: 4, Command: &api.MessageCommand{
+			MessageEdit: &api.MessageEdit{ID: "m1", Text: "hi!"},
+		}},
+	})
+	require.Equal(t, int64(4), agg.index)
+	require.Equal(t, []keys.ID{"bob", "alice"}, agg.messageReactions("m1")[0].senders)
+	m1 := &Message{ID: "m1", Text: []string{"hi"}}
+	agg.apply(m1, 0)
+	require.Equal(t, []string{"hi!"}, m1.Text)
+
+	// Reactions from before the add don't change.
+	require.Equal(t, []keys.ID{"bob"}, reactions[0].senders)
+}
+
+func visibleMessages(msgs []*api.Message) []*api.Message {
+	out := []*api.Message{}
+	for _, msg := range msgs {
+		if visible(msg) {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
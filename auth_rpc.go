@@ -73,8 +73,13 @@ func (s *service) authUnlock(ctx context.Context, secret string, typ AuthType, c
 	if err := s.openMessenger(ctx, mk); err != nil {
 		return "", nil, err
 	}
+	s.relay.resume()
+	if err := s.startSync(); err != nil {
+		return "", nil, err
+	}
 
 	logger.Infof("Unlocked (%s)", typ)
+	s.relay.Send(relayLockEvent(false))
 	token := s.authIr.registerToken(client)
 
 	return token, mk, nil
@@ -146,6 +151,11 @@ func (s *service) authLock(ctx context.Context) error {
 	defer s.unlockMtx.Unlock()
 	logger.Infof("Locking...")
 
+	s.relay.Send(relayLockEvent(true))
+	s.stopSync()
+	s.relay.suspend()
+	s.userDir.clear()
+	s.aggregates.clear()
 	s.db.Close()
 	s.authIr.clearTokens()
 	if err := s.keyring.Lock(); err != nil {
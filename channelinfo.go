@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/dstore/events"
+	"github.com/pkg/errors"
+)
+
+// channelInfo is the current channel name, description, topic and retention,
+// from channel info (and retention) commands, saved at /cinfo/{channel}.
+// Index is the remote index of the last message we applied.
+type channelInfo struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"desc,omitempty"`
+	// DescriptionSet if a command set (or cleared) the description, so it
+	// replaces the description the channel was created with.
+	DescriptionSet bool   `json:"descSet,omitempty"`
+	Topic          string `json:"topic,omitempty"`
+	Retention      int64  `json:"retention,omitempty"`
+	// Expiring if the channel has (or had) a retention, so messages may
+	// expire.
+	Expiring bool  `json:"expiring,omitempty"`
+	Index    int64 `json:"index"`
+}
+
+var channelNameRe = regexp.MustCompile("^[a-z0-9-]*$")
+
+func validateChannelName(name string) error {
+	if name == "" {
+		return errors.Errorf("no channel name specified")
+	}
+	if !channelNameRe.MatchString(name) {
+		return errors.Errorf("invalid channel name")
+	}
+	if len(name) > 16 {
+		return errors.Errorf("channel name too long (must be < 16)")
+	}
+	if isDMName(name) {
+		return errors.Errorf("invalid channel name (%s prefix is reserved)", dmPrefix)
+	}
+	return nil
+}
+
+// apply channel info (and retention) commands in msgs (after info.Index).
+func (i *channelInfo) apply(msgs []*api.Message) {
+	sorted := make([]*api.Message, len(msgs))
+	copy(sorted, msgs)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].RemoteIndex < sorted[b].RemoteIndex
+	})
+	for _, msg := range sorted {
+		if msg.RemoteIndex <= i.Index {
+			continue
+		}
+		i.Index = msg.RemoteIndex
+		if msg.Command == nil {
+			continue
+		}
+		if retention := msg.Command.ChannelRetention; retention != nil {
+			i.Retention = retention.TTL
+			if retention.TTL > 0 {
+				i.Expiring = true
+			}
+		}
+		if msg.Command.ChannelInfo == nil {
+			continue
+		}
+		info := msg.Command.ChannelInfo
+		// Ignore names that ChannelInfoSet wouldn't have sent.
+		if info.Name != "" && validateChannelName(info.Name) == nil {
+			i.Name = info.Name
+		}
+		if info.Description != "" || info.ClearDescription {
+			i.Description = info.Description
+			i.DescriptionSet = true
+		}
+		if info.Topic != "" || info.ClearTopic {
+			i.Topic = info.Topic
+		}
+	}
+}
+
+func (s *service) channelInfo(ctx context.Context, cid keys.ID) (*channelInfo, error) {
+	var info channelInfo
+	if _, err := s.db.Load(ctx, dstore.Path("cinfo", cid), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// updateChannelInfo applies channel info commands, from where we last left
+// off.
+func (s *service) updateChannelInfo(ctx context.Context, cid keys.ID) error {
+	info, err := s.channelInfo(ctx, cid)
+	if err != nil {
+		return err
+	}
+	msgs, err := s.messenger.MessagesFrom(cid, info.Index, events.Ascending, 0)
+	if err != nil {
+		return err
+	}
+	index := info.Index
+	info.apply(msgs)
+	if info.Index == index {
+		return nil
+	}
+	return s.db.Set(ctx, dstore.Path("cinfo", cid), dstore.From(info))
+}
+
+// channelInfoToRPC sets the channel name, description and topic from channel
+// info commands.
+func (s *service) channelInfoToRPC(ctx context.Context, c *Channel, cid keys.ID) error {
+	info, err := s.channelInfo(ctx, cid)
+	if err != nil {
+		return err
+	}
+	if info.Name != "" {
+		c.Name = info.Name
+	}
+	if info.DescriptionSet || info.Description != "" {
+		c.Description = info.Description
+	}
+	c.Topic = info.Topic
+	c.Retention = info.Retention
+	return nil
+}
+
+// channelName returns the current channel name.
+func (s *service) channelName(ctx context.Context, cid keys.ID) (string, error) {
+	channel, err := s.messenger.Channel(cid)
+	if err != nil {
+		return "", err
+	}
+	if channel == nil {
+		return "", errors.Errorf("channel not found")
+	}
+	info, err := s.channelInfo(ctx, cid)
+	if err != nil {
+		return "", err
+	}
+	if info.Name != "" {
+		return info.Name, nil
+	}
+	return channel.Name, nil
+}
+
+// ChannelInfoSet (RPC) sends a channel info command to change the channel
+// name, description or topic. Empty values are unchanged, the description
+// and topic can be cleared with ClearDescription and ClearTopic.
+func (s *service) ChannelInfoSet(ctx context.Context, req *ChannelInfoSetRequest) (*ChannelInfoSetResponse, error) {
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	info := &api.ChannelInfo{
+		Name:             strings.TrimSpace(req.Name),
+		Description:      strings.TrimSpace(req.Description),
+		Topic:            strings.TrimSpace(req.Topic),
+		ClearDescription: req.ClearDescription,
+		ClearTopic:       req.ClearTopic,
+	}
+	if info.Name == "" && info.Description == "" && info.Topic == "" && !info.ClearDescription && !info.ClearTopic {
+		return nil, errors.Errorf("no channel info specified")
+	}
+	if (info.Description != "" && info.ClearDescription) || (info.Topic != "" && info.ClearTopic) {
+		return nil, errors.Errorf("can't set and clear at the same time")
+	}
+	if info.Name != "" {
+		if err := validateChannelName(info.Name); err != nil {
+			return nil, err
+		}
+		// Direct messages are found by name.
+		dm, err := s.db.Exists(ctx, dstore.Path("dms", cid))
+		if err != nil {
+			return nil, err
+		}
+		if dm {
+			return nil, errors.Errorf("can't rename a direct message")
+		}
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := api.NewMessage(cid, account.ID).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{ChannelInfo: info}
+	if err := s.sendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	out, err := s.messageToRPC(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelInfoSetResponse{Message: out}, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getchill-app/http/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelInfoApply(t *testing.T) {
+	infoMsg := func(idx int64, info *api.ChannelInfo) *api.Message {
+		return &api.Message{RemoteIndex: idx, Command: &api.MessageCommand{ChannelInfo: info}}
+	}
+	msgs := []*api.Message{
+		infoMsg(3, &api.ChannelInfo{Topic: "release friday"}),
+		infoMsg(1, &api.ChannelInfo{Name: "dev", Description: "Development"}),
+		{ID: "m2", RemoteIndex: 2, Text: "hi"},
+		infoMsg(4, &api.ChannelInfo{Name: "Invalid Name"}),
+	}
+
+	info := &channelInfo{}
+	info.apply(msgs)
+	require.Equal(t, &channelInfo{Name: "dev", Description: "Development", DescriptionSet: true, Topic: "release friday", Index: 4}, info)
+
+	// Only applies messages after the index
+	info.apply([]*api.Message{
+		infoMsg(2, &api.ChannelInfo{Name: "old"}),
+		infoMsg(5, &api.ChannelInfo{Topic: "release monday"}),
+	})
+	require.Equal(t, "dev", info.Name)
+	require.Equal(t, "release monday", info.Topic)
+	require.Equal(t, int64(5), info.Index)
+
+	// Clear
+	info.apply([]*api.Message{
+		infoMsg(6, &api.ChannelInfo{ClearTopic: true}),
+		infoMsg(7, &api.ChannelInfo{ClearDescription: true}),
+	})
+	require.Equal(t, &channelInfo{Name: "dev", DescriptionSet: true, Index: 7}, info)
+}
+
+func TestValidateChannelName(t *testing.T) {
+	require.NoError(t, validateChannelName("testing-1"))
+	require.EqualError(t, validateChannelName(""), "no channel name specified")
+	require.EqualError(t, validateChannelName("Testing"), "invalid channel name")
+	require.EqualError(t, validateChannelName("abcdefghijklmnopq"), "channel name too long (must be < 16)")
+	require.EqualError(t, validateChannelName("dm-abc"), "invalid channel name (dm- prefix is reserved)")
+}
+
+func TestChannelInfoSet(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+
+	channel, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing", Description: "Testing"})
+	require.NoError(t, err)
+
+	// channelInfo pulls the channel, and returns it from Channels.
+	channelInfo := func(service *service) *Channel {
+		_, err := service.Channels(ctx, &ChannelsRequest{Update: true})
+		require.NoError(t, err)
+		_, err = service.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+		require.NoError(t, err)
+		channels, err := service.Channels(ctx, &ChannelsRequest{})
+		require.NoError(t, err)
+		for _, c := range channels.Channels {
+			if c.ID == channel.ID {
+				return c
+			}
+		}
+		t.Fatal("channel not found")
+		return nil
+	}
+
+	_, err = aliceService.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.ID})
+	require.EqualError(t, err, "no channel info specified")
+	_, err = aliceService.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.ID, Topic: "a", ClearTopic: true})
+	require.EqualError(t, err, "can't set and clear at the same time")
+	_, err = aliceService.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.ID, Name: "Invalid"})
+	require.EqualError(t, err, "invalid channel name")
+
+	resp, err := aliceService.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.ID, Name: "dev", Topic: "release friday"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Set the channel name to dev", "Set the channel topic to release friday"}, resp.Message.Text)
+
+	c := channelInfo(bobService)
+	require.Equal(t, "dev", c.Name)
+	require.Equal(t, "Testing", c.Description)
+	require.Equal(t, "release friday", c.Topic)
+
+	// Empty is unchanged
+	_, err = aliceService.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.ID, Description: "Development"})
+	require.NoError(t, err)
+	c = channelInfo(bobService)
+	require.Equal(t, "dev", c.Name)
+	require.Equal(t, "Development", c.Description)
+	require.Equal(t, "release friday", c.Topic)
+
+	// /topic and /description without args clear
+	send, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "/topic"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Cleared the channel topic"}, send.Message.Text)
+	send, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "/description"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Cleared the channel description"}, send.Message.Text)
+
+	c = channelInfo(bobService)
+	require.Equal(t, "dev", c.Name)
+	require.Equal(t, "", c.Description)
+	require.Equal(t, "", c.Topic)
+
+	c = channelInfo(aliceService)
+	require.Equal(t, "dev", c.Name)
+	require.Equal(t, "", c.Description)
+	require.Equal(t, "", c.Topic)
+}
@@ -2,14 +2,15 @@ package service
 
 import (
 	"context"
-	"regexp"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/getchill-app/http/api"
 	"github.com/getchill-app/messaging"
 	"github.com/keys-pub/keys"
 	kapi "github.com/keys-pub/keys/api"
+	"github.com/keys-pub/keys/dstore"
 	"github.com/pkg/errors"
 )
 
@@ -19,15 +20,49 @@ func (s *service) Channels(ctx context.Context, req *ChannelsRequest) (*Channels
 			return nil, err
 		}
 	}
+	if _, err := s.account(true); err != nil {
+		return nil, err
+	}
 	channels, err := s.messenger.Channels()
 	if err != nil {
 		return nil, err
 	}
+	dms, err := s.directMessages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := s.draftChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
 	out := make([]*Channel, 0, len(channels))
 	for _, channel := range channels {
-		out = append(out, channelToRPC(channel))
+		c := channelToRPC(channel)
+		if err := s.channelInfoToRPC(ctx, c, channel.ID); err != nil {
+			return nil, err
+		}
+		if dm, ok := dms[channel.ID]; ok {
+			if err := s.directMessageToRPC(ctx, c, dm.User); err != nil {
+				return nil, err
+			}
+		}
+		if req.Type != UnknownChannelType && c.Type != req.Type {
+			continue
+		}
+		unread, err := s.channelUnread(ctx, channel.ID)
+		if err != nil {
+			return nil, err
+		}
+		c.LastReadIndex = unread.ReadIndex
+		c.UnreadCount = int32(len(unread.Indexes))
+		c.HasDraft = drafts[channel.ID]
+		out = append(out, c)
 	}
+	// Direct messages are listed after channels.
 	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Type == DirectChannelType) != (out[j].Type == DirectChannelType) {
+			return out[j].Type == DirectChannelType
+		}
 		return out[i].Name < out[j].Name
 	})
 	return &ChannelsResponse{
@@ -37,15 +72,8 @@ func (s *service) Channels(ctx context.Context, req *ChannelsRequest) (*Channels
 
 func (s *service) ChannelCreate(ctx context.Context, req *ChannelCreateRequest) (*ChannelCreateResponse, error) {
 	name := strings.TrimSpace(req.Name)
-	if name == "" {
-		return nil, errors.Errorf("no channel name specified")
-	}
-	match, _ := regexp.MatchString("^[a-z0-9-]*$", name)
-	if !match {
-		return nil, errors.Errorf("invalid channel name")
-	}
-	if len(name) > 16 {
-		return nil, errors.Errorf("channel name too long (must be < 16)")
+	if err := validateChannelName(name); err != nil {
+		return nil, err
 	}
 	account, err := s.account(true)
 	if err != nil {
@@ -91,15 +119,27 @@ func (s *service) ChannelUsers(ctx context.Context, req *ChannelUsersRequest) (*
 	if err != nil {
 		return nil, err
 	}
+	names, err := s.userNames(ctx, users)
+	if err != nil {
+		return nil, err
+	}
 	out := []*ChannelUser{}
 	for _, u := range users {
-		name, err := s.userName(ctx, u)
+		identity, warning, err := s.userIdentity(ctx, u)
+		if err != nil {
+			return nil, err
+		}
+		verified, keyChanged, err := s.contactStatus(ctx, u, names[u])
 		if err != nil {
 			return nil, err
 		}
 		out = append(out, &ChannelUser{
-			ID:   u.String(),
-			Name: name,
+			ID:         u.String(),
+			Name:       names[u],
+			Identity:   identity,
+			Warning:    warning,
+			Verified:   verified,
+			KeyChanged: keyChanged,
 		})
 	}
 	return &ChannelUsersResponse{Users: out}, nil
@@ -114,6 +154,9 @@ func (s *service) ChannelUsersAdd(ctx context.Context, req *ChannelUsersAddReque
 	if err != nil {
 		return nil, err
 	}
+	if err := s.checkNotDirectMessage(ctx, cid); err != nil {
+		return nil, err
+	}
 	users := []keys.ID{}
 	for _, u := range req.Users {
 		user, err := keys.ParseID(u)
@@ -153,16 +196,72 @@ func (s *service) ChannelUsersRemove(ctx context.Context, req *ChannelUsersRemov
 	return &ChannelUsersRemoveResponse{}, nil
 }
 
+// ChannelInvite (RPC) adds users to a channel and posts an invite message.
+// Direct messages are only ever between 2 users, so to add someone, create a
+// channel instead.
+func (s *service) ChannelInvite(ctx context.Context, req *ChannelInviteRequest) (*ChannelInviteResponse, error) {
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if len(req.Recipients) == 0 {
+		return nil, errors.Errorf("no recipients specified")
+	}
+	if err := s.checkNotDirectMessage(ctx, cid); err != nil {
+		return nil, err
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+	channelKey, err := s.keyring.Key(cid)
+	if err != nil {
+		return nil, err
+	}
+	recipients := []keys.ID{}
+	for _, r := range req.Recipients {
+		recipient, err := s.findUser(ctx, strings.TrimPrefix(r, "@"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find %s", r)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	if err := s.client.ChannelUsersAdd(ctx, channelKey.AsEdX25519(), recipients); err != nil {
+		return nil, err
+	}
+
+	invites := make([]*api.ChannelInvite, 0, len(recipients))
+	for _, recipient := range recipients {
+		invites = append(invites, &api.ChannelInvite{
+			Channel:   cid,
+			Recipient: recipient,
+			Sender:    account.ID,
+		})
+	}
+	msg := api.NewMessage(cid, account.ID).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{ChannelInvites: invites}
+	if err := s.sendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	out, err := s.messageToRPC(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelInviteResponse{Message: out}, nil
+}
+
 func channelToRPC(channel *messaging.Channel) *Channel {
 	if channel == nil {
 		return nil
 	}
 
 	c := &Channel{
-		ID:      channel.ID.String(),
-		Name:    channel.Name,
-		Snippet: channel.Snippet,
-		Index:   channel.MessageIndex,
+		ID:          channel.ID.String(),
+		Name:        channel.Name,
+		Description: channel.Description,
+		Snippet:     channel.Snippet,
+		Index:       channel.MessageIndex,
 	}
 	if channel.Team != "" {
 		c.Type = TeamChannelType
@@ -172,16 +271,331 @@ func channelToRPC(channel *messaging.Channel) *Channel {
 	return c
 }
 
+// ChannelRead (RPC) sets the read marker for a channel.
+// Local UIs get a relay event, and the marker is sent to our other devices
+// (see readsync.go). If that fails (offline), the marker is only on this
+// device until a later read.
 func (s *service) ChannelRead(ctx context.Context, req *ChannelReadRequest) (*ChannelReadResponse, error) {
-	return nil, errors.Errorf("not implemented")
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	channel, err := s.messenger.Channel(cid)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, errors.Errorf("channel not found")
+	}
+	// Can't mark messages we don't have yet as read.
+	index := req.Index
+	if index == 0 || index > channel.MessageIndex {
+		index = channel.MessageIndex
+	}
+
+	moved, err := s.markRead(ctx, cid, index)
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		if err := s.sendReadMarker(ctx, cid, index); err != nil {
+			logger.Warningf("Failed to sync read marker for %s: %v", cid, err)
+		}
+	}
+	return &ChannelReadResponse{}, nil
+}
+
+// channelUnread is the read marker and unread messages for a channel, saved at
+// /unread/{channel}. It's updated as messages are pulled, so listing channels
+// doesn't have to go through every message.
+// Index is the remote index of the last message we checked.
+type channelUnread struct {
+	ReadIndex int64 `json:"read"`
+	// Indexes of unread messages (from others).
+	Indexes []int64 `json:"indexes,omitempty"`
+	Index   int64   `json:"index"`
+}
+
+// add unread messages in msgs (after u.Index).
+func (u *channelUnread) add(msgs []*api.Message, self keys.ID) {
+	sorted := make([]*api.Message, len(msgs))
+	copy(sorted, msgs)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].RemoteIndex < sorted[b].RemoteIndex
+	})
+	for _, msg := range sorted {
+		if msg.RemoteIndex <= u.Index {
+			continue
+		}
+		u.Index = msg.RemoteIndex
+		if msg.RemoteIndex > u.ReadIndex && msg.Sender != self && visible(msg) {
+			u.Indexes = append(u.Indexes, msg.RemoteIndex)
+		}
+	}
+}
+
+// read moves the read marker to index. Returns false if it didn't move.
+func (u *channelUnread) read(index int64) bool {
+	if index <= u.ReadIndex {
+		return false
+	}
+	u.ReadIndex = index
+	u.Indexes = filterIndexes(u.Indexes, func(i int64) bool { return i > index })
+	return true
+}
+
+// remove messages that are gone (expired).
+func (u *channelUnread) remove(msgs []*api.Message) {
+	removed := map[int64]bool{}
+	for _, msg := range msgs {
+		removed[msg.RemoteIndex] = true
+	}
+	u.Indexes = filterIndexes(u.Indexes, func(i int64) bool { return !removed[i] })
+}
+
+func filterIndexes(indexes []int64, keep func(i int64) bool) []int64 {
+	out := []int64{}
+	for _, i := range indexes {
+		if keep(i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// unreadLocks guards /unread/{channel}, which is changed by ChannelRead and
+// by pulls (which can run in the background at the same time).
+type unreadLocks struct {
+	sync.Mutex
+	channels map[keys.ID]*sync.Mutex
+}
+
+func newUnreadLocks() *unreadLocks {
+	return &unreadLocks{channels: map[keys.ID]*sync.Mutex{}}
+}
+
+// lock a channel, returns the unlock.
+func (l *unreadLocks) lock(channel keys.ID) func() {
+	l.Lock()
+	mtx, ok := l.channels[channel]
+	if !ok {
+		mtx = &sync.Mutex{}
+		l.channels[channel] = mtx
+	}
+	l.Unlock()
+	mtx.Lock()
+	return mtx.Unlock
+}
+
+// channelUnread returns the read marker and unread messages for a channel. If
+// we haven't counted yet, we go through the messages once.
+func (s *service) channelUnread(ctx context.Context, cid keys.ID) (*channelUnread, error) {
+	unlock := s.unreadLocks.lock(cid)
+	defer unlock()
+	return s.loadUnread(ctx, cid)
+}
+
+// loadUnread is channelUnread, with the channel already locked.
+func (s *service) loadUnread(ctx context.Context, cid keys.ID) (*channelUnread, error) {
+	var unread channelUnread
+	ok, err := s.db.Load(ctx, dstore.Path("unread", cid), &unread)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &unread, nil
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.messenger.Messages(cid)
+	if err != nil {
+		return nil, err
+	}
+	unread.add(msgs, account.ID)
+	if err := s.db.Set(ctx, dstore.Path("unread", cid), dstore.From(unread)); err != nil {
+		return nil, err
+	}
+	return &unread, nil
+}
+
+// updateUnread adds unread messages from msgs, which were just pulled.
+func (s *service) updateUnread(ctx context.Context, cid keys.ID, msgs []*api.Message) error {
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	unlock := s.unreadLocks.lock(cid)
+	defer unlock()
+	unread, err := s.loadUnread(ctx, cid)
+	if err != nil {
+		return err
+	}
+	index := unread.Index
+	unread.add(msgs, account.ID)
+	if unread.Index == index {
+		return nil
+	}
+	return s.db.Set(ctx, dstore.Path("unread", cid), dstore.From(unread))
+}
+
+// removeUnread removes messages with ids (from msgs) that are gone.
+func (s *service) removeUnread(ctx context.Context, cid keys.ID, msgs []*api.Message, ids []string) error {
+	unlock := s.unreadLocks.lock(cid)
+	defer unlock()
+	unread, err := s.loadUnread(ctx, cid)
+	if err != nil {
+		return err
+	}
+	remove := map[string]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+	removed := []*api.Message{}
+	for _, msg := range msgs {
+		if remove[msg.ID] {
+			removed = append(removed, msg)
+		}
+	}
+	unread.remove(removed)
+	return s.db.Set(ctx, dstore.Path("unread", cid), dstore.From(unread))
 }
 
 func (s *service) ChannelLeave(ctx context.Context, req *ChannelLeaveRequest) (*ChannelLeaveResponse, error) {
-	// channel, err := keys.ParseID(req.Channel)
-	// if err != nil {
-	// 	return nil, errors.Wrapf(err, "invalid channel")
-	// }
-	return nil, errors.Errorf("not implemented")
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if err := s.channelLeave(ctx, cid); err != nil {
+		return nil, err
+	}
+	return &ChannelLeaveResponse{}, nil
+}
+
+// channelLeft is saved when we leave a channel, so we don't add it back on
+// the next update. For a users channel, it's removed if we are added back. For
+// a team channel, it's removed if we join it again (ChannelJoin).
+type channelLeft struct {
+	ID        keys.ID `json:"id"`
+	Timestamp int64   `json:"ts"`
+}
+
+func (s *service) channelLeave(ctx context.Context, cid keys.ID) error {
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	channel, err := s.messenger.Channel(cid)
+	if err != nil {
+		return err
+	}
+	if channel == nil {
+		return errors.Errorf("channel not found")
+	}
+	channelKey, err := s.keyring.Key(cid)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Leaving channel %s", cid)
+
+	// Team channels are available to everyone in the team, so there is no
+	// user to remove on the server, we only remember that we left.
+	if channel.Team == "" {
+		if err := s.client.ChannelUsersRemove(ctx, channelKey.AsEdX25519(), []keys.ID{account.ID}); err != nil {
+			return err
+		}
+	}
+	left := &channelLeft{ID: cid, Timestamp: s.clock.NowMillis()}
+	if err := s.db.Set(ctx, dstore.Path("left", cid), dstore.From(left)); err != nil {
+		return err
+	}
+
+	// Remove channel key (and relay token)
+	if _, err := s.keyring.Delete(cid); err != nil {
+		return err
+	}
+	if err := s.messenger.DeleteChannel(cid); err != nil {
+		return err
+	}
+	s.aggregates.remove(cid)
+	if err := s.unindexChannel(ctx, cid); err != nil {
+		return err
+	}
+	if _, err := s.db.Delete(ctx, dstore.Path("cinfo", cid)); err != nil {
+		return err
+	}
+	if _, err := s.db.Delete(ctx, dstore.Path("unread", cid)); err != nil {
+		return err
+	}
+	if err := s.messenger.DeleteSystemMessages(cid); err != nil {
+		return err
+	}
+	if err := s.clearDraft(ctx, cid); err != nil {
+		return err
+	}
+	if err := s.deleteScheduledMessages(ctx, cid); err != nil {
+		return err
+	}
+	if err := s.deleteSavedMessages(ctx, cid); err != nil {
+		return err
+	}
+	if err := s.deleteOutboxMessages(ctx, cid); err != nil {
+		return err
+	}
+
+	s.relay.Send(relayChannelsEvent())
+	return nil
+}
+
+// ChannelJoin (RPC) joins a team channel we left.
+func (s *service) ChannelJoin(ctx context.Context, req *ChannelJoinRequest) (*ChannelJoinResponse, error) {
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	left, err := s.channelLeft(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	if !left {
+		existing, err := s.messenger.Channel(cid)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, errors.Errorf("already in channel")
+		}
+		return nil, errors.Errorf("channel not found")
+	}
+
+	logger.Debugf("Joining channel %s", cid)
+	if _, err := s.db.Delete(ctx, dstore.Path("left", cid)); err != nil {
+		return nil, err
+	}
+	if err := s.updateChannels(ctx); err != nil {
+		return nil, err
+	}
+	channel, err := s.messenger.Channel(cid)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		// Not a team channel, and we weren't added back.
+		return nil, errors.Errorf("channel not found")
+	}
+	s.relay.Send(relayChannelsEvent())
+
+	c := channelToRPC(channel)
+	if err := s.channelInfoToRPC(ctx, c, cid); err != nil {
+		return nil, err
+	}
+	return &ChannelJoinResponse{Channel: c}, nil
+}
+
+func (s *service) channelLeft(ctx context.Context, cid keys.ID) (bool, error) {
+	return s.db.Exists(ctx, dstore.Path("left", cid))
 }
 
 func (s *service) updateChannels(ctx context.Context) error {
@@ -199,7 +613,28 @@ func (s *service) updateChannels(ctx context.Context) error {
 		return err
 	}
 	logger.Debugf("Found %d channel(s)", len(channels))
+	readSyncID := readSyncKey(account.AsEdX25519()).ID()
+	var readSync *api.Channel
+	pull := []keys.ID{}
 	for _, channel := range channels {
+		if channel.ID == readSyncID {
+			readSync = channel
+			continue
+		}
+		left, err := s.channelLeft(ctx, channel.ID)
+		if err != nil {
+			return err
+		}
+		if left {
+			// If we are listed on a users channel again, we were re-added.
+			if channel.TeamKey != nil {
+				continue
+			}
+			if _, err := s.db.Delete(ctx, dstore.Path("left", channel.ID)); err != nil {
+				return err
+			}
+		}
+
 		var channelKey *keys.EdX25519Key
 		if channel.TeamKey != nil {
 			k, err := api.DecryptKey(channel.TeamKey, team.AsEdX25519())
@@ -246,6 +681,9 @@ func (s *service) updateChannels(ctx context.Context) error {
 		if err != nil {
 			return err
 		}
+		if err := s.checkDirectMessage(ctx, ch, channelKey, account.ID); err != nil {
+			return err
+		}
 
 		// If we don't have it, add it
 		if existing == nil {
@@ -259,11 +697,20 @@ func (s *service) updateChannels(ctx context.Context) error {
 		}
 
 		// Check if we need to pull messages
-		if ch.MessageIndex != channel.Index {
-			if err := s.PullMessages(ctx, channelKey.ID()); err != nil {
-				return err
-			}
+		index := int64(0)
+		if existing != nil {
+			index = existing.MessageIndex
+		}
+		if index != channel.Index {
+			pull = append(pull, channelKey.ID())
 		}
 	}
+	if err := s.pullChannels(ctx, pull); err != nil {
+		return err
+	}
+	// After pulling, so we have the messages the markers are for.
+	if readSync != nil {
+		return s.pullReadMarkers(ctx, readSync)
+	}
 	return nil
 }
@@ -3,7 +3,12 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -76,3 +81,283 @@ func TestChannelMessages(t *testing.T) {
 	require.Equal(t, 1, len(msgsBob.Messages))
 	require.Equal(t, []string{"hi bob"}, msgsBob.Messages[0].Text)
 }
+
+func TestChannelLeave(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+
+	channels, err := aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(channels.Channels))
+
+	// Pending message in the outbox
+	cid, err := keys.ParseID(channelCreate.ID)
+	require.NoError(t, err)
+	msg := api.NewMessage(cid, alice.ID()).WithText("pending").WithTimestamp(env.clock.NowMillis())
+	_, err = aliceService.outboxAdd(ctx, msg, errors.Errorf("offline"))
+	require.NoError(t, err)
+
+	_, err = aliceService.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: channelCreate.ID})
+	require.NoError(t, err)
+
+	oms, err := aliceService.outboxMessages(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(oms))
+
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(channels.Channels))
+
+	// Shouldn't come back on update
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(channels.Channels))
+
+	_, err = aliceService.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: channelCreate.ID})
+	require.EqualError(t, err, "channel not found")
+
+	// Join again
+	join, err := aliceService.ChannelJoin(ctx, &ChannelJoinRequest{Channel: channelCreate.ID})
+	require.NoError(t, err)
+	require.Equal(t, channelCreate.ID, join.Channel.ID)
+	require.Equal(t, "testing", join.Channel.Name)
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(channels.Channels))
+
+	_, err = aliceService.ChannelJoin(ctx, &ChannelJoinRequest{Channel: channelCreate.ID})
+	require.EqualError(t, err, "already in channel")
+}
+
+func TestChannelRead(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+
+	_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channelCreate.ID, Text: "hi bob"})
+	require.NoError(t, err)
+	_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channelCreate.ID, Text: "are you there?"})
+	require.NoError(t, err)
+
+	channelsBob, err := bobService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(channelsBob.Channels))
+	require.Equal(t, int32(2), channelsBob.Channels[0].UnreadCount)
+	require.Equal(t, int64(0), channelsBob.Channels[0].LastReadIndex)
+
+	_, err = bobService.ChannelRead(ctx, &ChannelReadRequest{Channel: channelCreate.ID})
+	require.NoError(t, err)
+
+	channelsBob, err = bobService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), channelsBob.Channels[0].UnreadCount)
+	require.Equal(t, channelsBob.Channels[0].Index, channelsBob.Channels[0].LastReadIndex)
+
+	// Alice's own messages are never unread
+	channelsAlice, err := aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, int32(0), channelsAlice.Channels[0].UnreadCount)
+
+	// Reading past the last message doesn't mark later messages as read
+	_, err = bobService.ChannelRead(ctx, &ChannelReadRequest{Channel: channelCreate.ID, Index: 1000})
+	require.NoError(t, err)
+	channelsBob, err = bobService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, channelsBob.Channels[0].Index, channelsBob.Channels[0].LastReadIndex)
+	_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channelCreate.ID, Text: "hello?"})
+	require.NoError(t, err)
+	channelsBob, err = bobService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), channelsBob.Channels[0].UnreadCount)
+}
+
+func TestChannelUnread(t *testing.T) {
+	unread := &channelUnread{}
+	unread.add([]*api.Message{
+		{ID: "m2", Sender: "bob", RemoteIndex: 2},
+		{ID: "m1", Sender: "alice", RemoteIndex: 1},
+		{ID: "m3", Sender: "bob", RemoteIndex: 3, Parent: "m2"},
+		{ID: "m4", Sender: "bob", RemoteIndex: 4, Command: &api.MessageCommand{
+			MessageReaction: &api.MessageReaction{ID: "m2", Emoji: "👍"},
+		}},
+		{ID: "m5", Sender: "bob", RemoteIndex: 5},
+	}, "alice")
+	require.Equal(t, []int64{2, 5}, unread.Indexes)
+	require.Equal(t, int64(5), unread.Index)
+
+	// Already counted
+	unread.add([]*api.Message{{ID: "m5", Sender: "bob", RemoteIndex: 5}}, "alice")
+	require.Equal(t, []int64{2, 5}, unread.Indexes)
+
+	require.True(t, unread.read(2))
+	require.Equal(t, []int64{5}, unread.Indexes)
+	require.False(t, unread.read(1))
+	require.Equal(t, int64(2), unread.ReadIndex)
+
+	unread.add([]*api.Message{{ID: "m6", Sender: "bob", RemoteIndex: 6}}, "alice")
+	unread.remove([]*api.Message{{ID: "m5", RemoteIndex: 5}})
+	require.Equal(t, []int64{6}, unread.Indexes)
+}
+
+func TestChannelInvite(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+	testSetUsername(t, aliceService, "alice")
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	testSetUsername(t, bobService, "bob")
+
+	charlieServiceEnv, charlieCloseFn := newTestTeamUser(t, env, "charlie@keys.pub", charlie, aliceService)
+	defer charlieCloseFn()
+	charlieService := charlieServiceEnv.service
+	testSetUsername(t, charlieService, "charlie")
+
+	channel, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing"})
+	require.NoError(t, err)
+
+	_, err = aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.ID})
+	require.EqualError(t, err, "no recipients specified")
+	_, err = aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.ID, Recipients: []string{"nobody"}})
+	require.EqualError(t, err, "failed to find nobody: user not found")
+
+	invite, err := aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.ID, Recipients: []string{"@bob"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice invited bob"}, invite.Message.Text)
+
+	// /invite
+	send, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "/invite @charlie"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice invited charlie"}, send.Message.Text)
+
+	users, err := aliceService.ChannelUsers(ctx, &ChannelUsersRequest{Channel: channel.ID})
+	require.NoError(t, err)
+	ids := []string{}
+	for _, u := range users.Users {
+		ids = append(ids, u.ID)
+	}
+	require.ElementsMatch(t, []string{alice.ID().String(), bob.ID().String(), charlie.ID().String()}, ids)
+
+	// Bob sees the invites
+	msgs, err := bobService.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+	require.NoError(t, err)
+	texts := []string{}
+	for _, msg := range msgs.Messages {
+		texts = append(texts, msg.Text...)
+	}
+	require.Equal(t, []string{"alice invited bob", "alice invited charlie"}, texts)
+
+	// Can't invite to a direct message
+	dm, err := aliceService.DirectMessage(ctx, &DirectMessageRequest{User: "bob"})
+	require.NoError(t, err)
+	_, err = aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: dm.Channel.ID, Recipients: []string{"charlie"}})
+	require.EqualError(t, err, "can't add users to a direct message")
+	_, err = aliceService.ChannelUsersAdd(ctx, &ChannelUsersAddRequest{Channel: dm.Channel.ID, Users: []string{charlie.ID().String()}})
+	require.EqualError(t, err, "can't add users to a direct message")
+
+	// /invite shows the error in the channel
+	send, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: dm.Channel.ID, Text: "/invite @charlie"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"can't add users to a direct message"}, send.Message.Text)
+
+	users, err = aliceService.ChannelUsers(ctx, &ChannelUsersRequest{Channel: dm.Channel.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(users.Users))
+}
+
+func TestChannelReadSync(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	cid, err := keys.ParseID(channelCreate.ID)
+	require.NoError(t, err)
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	_, err = bobService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	_, err = bobService.MessageSend(ctx, &MessageSendRequest{Channel: channelCreate.ID, Text: "hi alice"})
+	require.NoError(t, err)
+	_, err = bobService.MessageSend(ctx, &MessageSendRequest{Channel: channelCreate.ID, Text: "are you there?"})
+	require.NoError(t, err)
+
+	channels, err := aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), channels.Channels[0].UnreadCount)
+	_, err = aliceService.ChannelRead(ctx, &ChannelReadRequest{Channel: channelCreate.ID})
+	require.NoError(t, err)
+
+	// Like another device, with no read state of its own
+	_, err = aliceService.db.Delete(ctx, dstore.Path("unread", cid))
+	require.NoError(t, err)
+	_, err = aliceService.db.Delete(ctx, dstore.Path("readsync", "state"))
+	require.NoError(t, err)
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int32(2), channels.Channels[0].UnreadCount)
+
+	// Gets the read marker on update, and the read sync channel isn't listed
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(channels.Channels))
+	require.Equal(t, int32(0), channels.Channels[0].UnreadCount)
+	require.Equal(t, channels.Channels[0].Index, channels.Channels[0].LastReadIndex)
+}
+
+func TestUnreadLocks(t *testing.T) {
+	locks := newUnreadLocks()
+	unlock := locks.lock(keys.ID("channel1"))
+
+	// Other channels aren't blocked
+	locks.lock(keys.ID("channel2"))()
+
+	locked := make(chan struct{})
+	go func() {
+		defer locks.lock(keys.ID("channel1"))()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("channel lock wasn't held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
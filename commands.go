@@ -2,34 +2,291 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strings"
+	"unicode"
 
+	"github.com/keys-pub/keys"
 	"github.com/pkg/errors"
 )
 
-func (s *service) command(ctx context.Context, cmd string, channel string) (*Message, error) {
+// commandArg is an argument spec for a slash command.
+type commandArg struct {
+	Name string
+	Type CommandArgType
+	// Optional if the argument can be omitted (only the last argument).
+	Optional bool
+	// Variadic if the argument takes the remaining fields (last argument).
+	Variadic bool
+	// Rest if the argument takes the remaining text, with spacing preserved
+	// (last argument).
+	Rest bool
+}
+
+// commandSpec is a slash command in the registry.
+type commandSpec struct {
+	Name string
+	Args []*commandArg
+	Help string
+	// Run the command with validated args.
+	Run func(ctx context.Context, channel keys.ID, args []string) (*Message, error)
+}
+
+// commands returns the command registry, sorted by name.
+func (s *service) commands() []*commandSpec {
+	cmds := []*commandSpec{
+		{
+			Name: "/create",
+			Args: []*commandArg{{Name: "name", Type: CommandArgChannel}},
+			Help: "Create a channel.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				if _, err := s.ChannelCreate(ctx, &ChannelCreateRequest{Name: args[0]}); err != nil {
+					return nil, err
+				}
+				return s.addSystemMessage(ctx, channel, fmt.Sprintf("Created #%s", args[0]))
+			},
+		},
+		{
+			Name: "/description",
+			Args: []*commandArg{{Name: "description", Rest: true, Optional: true}},
+			Help: "Set the channel description, or clear it.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				req := &ChannelInfoSetRequest{Channel: channel.String(), ClearDescription: true}
+				if len(args) > 0 {
+					req = &ChannelInfoSetRequest{Channel: channel.String(), Description: args[0]}
+				}
+				resp, err := s.ChannelInfoSet(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				return resp.Message, nil
+			},
+		},
+		{
+			Name: "/help",
+			Args: []*commandArg{{Name: "command", Optional: true}},
+			Help: "Show help for commands.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				text, err := s.commandHelp(args)
+				if err != nil {
+					return nil, err
+				}
+				return s.addSystemMessage(ctx, channel, text...)
+			},
+		},
+		{
+			Name: "/invite",
+			Args: []*commandArg{{Name: "user", Type: CommandArgUser, Variadic: true}},
+			Help: "Invite users to the channel.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				resp, err := s.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.String(), Recipients: args})
+				if err != nil {
+					return nil, err
+				}
+				return resp.Message, nil
+			},
+		},
+		{
+			Name: "/leave",
+			Help: "Leave the channel.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				name, err := s.channelName(ctx, channel)
+				if err != nil {
+					return nil, err
+				}
+				if _, err := s.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: channel.String()}); err != nil {
+					return nil, err
+				}
+				// Not saved, since the channel is gone.
+				return s.newSystemMessage(channel, fmt.Sprintf("You left #%s", name)).toRPC(), nil
+			},
+		},
+		{
+			Name: "/rename",
+			Args: []*commandArg{{Name: "name", Type: CommandArgChannel}},
+			Help: "Rename the channel.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				resp, err := s.ChannelInfoSet(ctx, &ChannelInfoSetRequest{Channel: channel.String(), Name: args[0]})
+				if err != nil {
+					return nil, err
+				}
+				return resp.Message, nil
+			},
+		},
+		{
+			Name: "/retention",
+			Args: []*commandArg{{Name: "duration"}},
+			Help: "Set how long until messages disappear, for example 1h, 7d or off.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				retention, err := parseRetention(args[0])
+				if err != nil {
+					return nil, err
+				}
+				resp, err := s.ChannelRetentionSet(ctx, &ChannelRetentionSetRequest{Channel: channel.String(), Retention: retention})
+				if err != nil {
+					return nil, err
+				}
+				return resp.Message, nil
+			},
+		},
+		{
+			Name: "/topic",
+			Args: []*commandArg{{Name: "topic", Rest: true, Optional: true}},
+			Help: "Set the channel topic, or clear it.",
+			Run: func(ctx context.Context, channel keys.ID, args []string) (*Message, error) {
+				req := &ChannelInfoSetRequest{Channel: channel.String(), ClearTopic: true}
+				if len(args) > 0 {
+					req = &ChannelInfoSetRequest{Channel: channel.String(), Topic: args[0]}
+				}
+				resp, err := s.ChannelInfoSet(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				return resp.Message, nil
+			},
+		},
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+func (s *service) findCommand(name string) *commandSpec {
+	for _, c := range s.commands() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (s *service) command(ctx context.Context, cmd string, channel keys.ID) (*Message, error) {
 	fields := strings.Fields(cmd)
 	if len(fields) == 0 {
 		return nil, errors.Errorf("no command")
 	}
+	spec := s.findCommand(fields[0])
+	if spec == nil {
+		return nil, errors.Errorf("unrecognized command %s (see /help)", fields[0])
+	}
+	args, err := spec.parse(cmd)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("Channel command: %s %v", spec.Name, args)
+	return spec.Run(ctx, channel, args)
+}
+
+// parse returns args from the command text, checking them against the spec.
+func (c *commandSpec) parse(cmd string) ([]string, error) {
+	rest := commandText(cmd)
+	args := []string{}
+	for i, arg := range c.Args {
+		last := i == len(c.Args)-1
+		if rest == "" {
+			if arg.Optional {
+				break
+			}
+			return nil, errors.Errorf("usage: %s", c.usage())
+		}
+		switch {
+		case last && arg.Rest:
+			args = append(args, rest)
+			rest = ""
+		case last && arg.Variadic:
+			args = append(args, strings.Fields(rest)...)
+			rest = ""
+		default:
+			field := strings.Fields(rest)[0]
+			args = append(args, field)
+			rest = commandText(rest)
+		}
+	}
+	if rest != "" {
+		return nil, errors.Errorf("usage: %s", c.usage())
+	}
+	return args, nil
+}
+
+// usage returns the command with its args, for example "/invite <user>...".
+func (c *commandSpec) usage() string {
+	out := []string{c.Name}
+	for _, arg := range c.Args {
+		s := arg.Name
+		if arg.Variadic || arg.Rest {
+			s = s + "..."
+		}
+		if arg.Optional {
+			out = append(out, "["+s+"]")
+		} else {
+			out = append(out, "<"+s+">")
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func (c *commandSpec) toRPC() *Command {
+	args := make([]*CommandArg, 0, len(c.Args))
+	for _, arg := range c.Args {
+		args = append(args, &CommandArg{
+			Name:     arg.Name,
+			Type:     arg.Type,
+			Optional: arg.Optional,
+			Variadic: arg.Variadic,
+			Rest:     arg.Rest,
+		})
+	}
+	return &Command{
+		Name:  c.Name,
+		Args:  args,
+		Usage: c.usage(),
+		Help:  c.Help,
+	}
+}
 
-	cmd0, args := fields[0], fields[1:]
-	logger.Debugf("Channel command: %s %v", cmd0, args)
+// commandText returns the text after the command, with spacing preserved.
+func commandText(cmd string) string {
+	cmd = strings.TrimSpace(cmd)
+	i := strings.IndexFunc(cmd, unicode.IsSpace)
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(cmd[i:])
+}
 
-	switch cmd0 {
-	case "/leave":
-		_, err := s.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: channel})
-		if err != nil {
-			return nil, err
+// commandHelp returns help for all commands, or for a command.
+func (s *service) commandHelp(args []string) ([]string, error) {
+	cmds := s.commands()
+	if len(args) > 0 {
+		name := args[0]
+		if !strings.HasPrefix(name, "/") {
+			name = "/" + name
 		}
-		return nil, nil
-	case "/create":
-		_, err := s.ChannelCreate(ctx, &ChannelCreateRequest{Name: args[0]})
-		if err != nil {
-			return nil, err
+		spec := s.findCommand(name)
+		if spec == nil {
+			return nil, errors.Errorf("unrecognized command %s", args[0])
 		}
-		return nil, nil
+		cmds = []*commandSpec{spec}
+	}
+	text := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		text = append(text, fmt.Sprintf("%s  %s", c.usage(), c.Help))
 	}
+	return text, nil
+}
 
-	return nil, errors.Errorf("unrecognized command")
+// Commands (RPC) returns slash commands, for help and completion.
+func (s *service) Commands(ctx context.Context, req *CommandsRequest) (*CommandsResponse, error) {
+	prefix := req.Prefix
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	out := []*Command{}
+	for _, c := range s.commands() {
+		if !strings.HasPrefix(c.Name, prefix) {
+			continue
+		}
+		out = append(out, c.toRPC())
+	}
+	return &CommandsResponse{Commands: out}, nil
 }
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandParse(t *testing.T) {
+	s := &service{}
+
+	args, err := s.findCommand("/create").parse("/create testing")
+	require.NoError(t, err)
+	require.Equal(t, []string{"testing"}, args)
+
+	_, err = s.findCommand("/create").parse("/create")
+	require.EqualError(t, err, "usage: /create <name>")
+	_, err = s.findCommand("/create").parse("/create a b")
+	require.EqualError(t, err, "usage: /create <name>")
+
+	args, err = s.findCommand("/invite").parse("/invite @alice  @bob")
+	require.NoError(t, err)
+	require.Equal(t, []string{"@alice", "@bob"}, args)
+
+	args, err = s.findCommand("/topic").parse("/topic  release  friday ")
+	require.NoError(t, err)
+	require.Equal(t, []string{"release  friday"}, args)
+
+	args, err = s.findCommand("/topic").parse("/topic")
+	require.NoError(t, err)
+	require.Equal(t, []string{}, args)
+
+	args, err = s.findCommand("/help").parse("/help")
+	require.NoError(t, err)
+	require.Equal(t, []string{}, args)
+
+	_, err = s.findCommand("/leave").parse("/leave now")
+	require.EqualError(t, err, "usage: /leave")
+}
+
+func TestCommandText(t *testing.T) {
+	require.Equal(t, "release  friday", commandText("/topic  release  friday "))
+	require.Equal(t, "", commandText("/topic"))
+	require.Equal(t, "", commandText(" /topic  "))
+	// Used by parse to drop the first arg
+	require.Equal(t, "b c", commandText("a b c"))
+}
+
+func TestCommand(t *testing.T) {
+	s := &service{}
+	ctx := context.TODO()
+
+	// Shouldn't panic without args
+	_, err := s.command(ctx, "/create", "")
+	require.EqualError(t, err, "usage: /create <name>")
+
+	_, err = s.command(ctx, "/unknown", "")
+	require.EqualError(t, err, "unrecognized command /unknown (see /help)")
+}
+
+func TestCommandHelp(t *testing.T) {
+	s := &service{}
+
+	text, err := s.commandHelp([]string{"invite"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/invite <user...>  Invite users to the channel."}, text)
+
+	text, err = s.commandHelp(nil)
+	require.NoError(t, err)
+	require.Equal(t, len(s.commands()), len(text))
+
+	_, err = s.commandHelp([]string{"unknown"})
+	require.EqualError(t, err, "unrecognized command unknown")
+}
+
+func TestCommands(t *testing.T) {
+	s := &service{}
+	resp, err := s.Commands(context.TODO(), &CommandsRequest{Prefix: "/in"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Commands))
+	require.Equal(t, "/invite", resp.Commands[0].Name)
+	require.Equal(t, "/invite <user...>", resp.Commands[0].Usage)
+	require.Equal(t, CommandArgUser, resp.Commands[0].Args[0].Type)
+	require.True(t, resp.Commands[0].Args[0].Variadic)
+
+	resp, err = s.Commands(context.TODO(), &CommandsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, len(s.commands()), len(resp.Commands))
+}
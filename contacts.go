@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/pkg/errors"
+)
+
+// contactVerification is saved at /verified/{username} when we compare safety
+// numbers with a user. If the username is later used with a different key,
+// we flag it.
+type contactVerification struct {
+	Username   string  `json:"username"`
+	KID        keys.ID `json:"kid"`
+	VerifiedAt int64   `json:"verifiedAt"`
+}
+
+// safetyNumber returns 60 digits (in 12 groups of 5) for a pair of keys. It's
+// the same regardless of the order of the keys.
+func safetyNumber(a keys.ID, b keys.ID) string {
+	h := safetyHash(a, b)
+	groups := make([]string, 0, 12)
+	for i := 0; i < 12; i++ {
+		// 5 bytes (40 bits) per group
+		chunk := make([]byte, 8)
+		copy(chunk[3:], h[i*5:i*5+5])
+		n := binary.BigEndian.Uint64(chunk) % 100000
+		groups = append(groups, fmt.Sprintf("%05d", n))
+	}
+	return strings.Join(groups, " ")
+}
+
+func safetyHash(a keys.ID, b keys.ID) [64]byte {
+	if b < a {
+		a, b = b, a
+	}
+	return sha512.Sum512([]byte("getchill.app/safety-number\n" + a.String() + "\n" + b.String()))
+}
+
+// safetyQRPayload is what we show as a QR code. The other user scans it and
+// checks it matches their keys.
+func safetyQRPayload(a keys.ID, b keys.ID) string {
+	if b < a {
+		a, b = b, a
+	}
+	return fmt.Sprintf("chill:safety:1:%s:%s", a, b)
+}
+
+// contactVerification returns the verification for username, if any.
+func (s *service) contactVerification(ctx context.Context, username string) (*contactVerification, error) {
+	if username == "" {
+		return nil, nil
+	}
+	var cv contactVerification
+	ok, err := s.db.Load(ctx, dstore.Path("verified", username), &cv)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &cv, nil
+}
+
+// contactStatus returns whether the user is verified, or if we verified the
+// username with a different key.
+func (s *service) contactStatus(ctx context.Context, kid keys.ID, username string) (verified bool, keyChanged bool, err error) {
+	cv, err := s.contactVerification(ctx, username)
+	if err != nil {
+		return false, false, err
+	}
+	if cv == nil {
+		return false, false, nil
+	}
+	if cv.KID != kid {
+		return false, true, nil
+	}
+	return true, false, nil
+}
+
+// SafetyNumber (RPC) returns a safety number to compare with another user.
+func (s *service) SafetyNumber(ctx context.Context, req *SafetyNumberRequest) (*SafetyNumberResponse, error) {
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+	kid, username, err := s.contactUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	cv, err := s.contactVerification(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SafetyNumberResponse{
+		SafetyNumber: safetyNumber(account.ID, kid),
+		QRPayload:    safetyQRPayload(account.ID, kid),
+	}
+	if cv != nil && cv.KID == kid {
+		resp.Verified = true
+		resp.VerifiedAt = cv.VerifiedAt
+	}
+	return resp, nil
+}
+
+// ContactVerify (RPC) marks a user as verified, after comparing safety
+// numbers.
+func (s *service) ContactVerify(ctx context.Context, req *ContactVerifyRequest) (*ContactVerifyResponse, error) {
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+	kid, username, err := s.contactUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	if kid == account.ID {
+		return nil, errors.Errorf("can't verify yourself")
+	}
+	if req.QRPayload != "" && req.QRPayload != safetyQRPayload(account.ID, kid) {
+		return nil, errors.Errorf("safety number doesn't match")
+	}
+	cv := &contactVerification{
+		Username:   username,
+		KID:        kid,
+		VerifiedAt: s.clock.NowMillis(),
+	}
+	logger.Infof("Verified %s (%s)", username, kid)
+	if err := s.db.Set(ctx, dstore.Path("verified", username), dstore.From(cv)); err != nil {
+		return nil, err
+	}
+	return &ContactVerifyResponse{}, nil
+}
+
+// ContactUnverify (RPC) removes a verification.
+func (s *service) ContactUnverify(ctx context.Context, req *ContactUnverifyRequest) (*ContactUnverifyResponse, error) {
+	_, username, err := s.contactUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Delete(ctx, dstore.Path("verified", username)); err != nil {
+		return nil, err
+	}
+	return &ContactUnverifyResponse{}, nil
+}
+
+// contactUser returns the kid and username for a KID or username.
+func (s *service) contactUser(ctx context.Context, user string) (keys.ID, string, error) {
+	if user == "" {
+		return "", "", errors.Errorf("no user specified")
+	}
+	kid, err := s.findUser(ctx, user)
+	if err != nil {
+		return "", "", err
+	}
+	username, err := s.userName(ctx, kid)
+	if err != nil {
+		return "", "", err
+	}
+	if username == "" {
+		return "", "", errors.Errorf("user has no username")
+	}
+	return kid, username, nil
+}
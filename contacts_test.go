@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/keys-pub/keys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	alice := keys.ID("kex1alice")
+	bob := keys.ID("kex1bob")
+	charlie := keys.ID("kex1charlie")
+
+	sn := safetyNumber(alice, bob)
+	require.Equal(t, sn, safetyNumber(bob, alice))
+	require.NotEqual(t, sn, safetyNumber(alice, charlie))
+
+	groups := strings.Split(sn, " ")
+	require.Equal(t, 12, len(groups))
+	for _, g := range groups {
+		require.Equal(t, 5, len(g))
+	}
+
+	require.Equal(t, safetyQRPayload(alice, bob), safetyQRPayload(bob, alice))
+	require.Equal(t, "chill:safety:1:kex1alice:kex1bob", safetyQRPayload(bob, alice))
+}
+
+func TestContactKeyChanged(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	testSetUsername(t, bobService, "bob")
+
+	channel, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing"})
+	require.NoError(t, err)
+	_, err = aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.ID, Recipients: []string{"bob"}})
+	require.NoError(t, err)
+	_, err = bobService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	fromBob, err := bobService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "hi alice"})
+	require.NoError(t, err)
+
+	// Alice verifies bob
+	sn, err := bobService.SafetyNumber(ctx, &SafetyNumberRequest{User: alice.ID().String()})
+	require.NoError(t, err)
+	_, err = aliceService.ContactVerify(ctx, &ContactVerifyRequest{User: "bob", QRPayload: sn.QRPayload})
+	require.NoError(t, err)
+
+	msgs, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+	require.NoError(t, err)
+	require.Equal(t, "bob", msgs.Messages[len(msgs.Messages)-1].Sender)
+	require.True(t, msgs.Messages[len(msgs.Messages)-1].SenderVerified)
+	require.False(t, msgs.Messages[len(msgs.Messages)-1].SenderKeyChanged)
+
+	// Bob changes username, and charlie takes "bob", with a different key
+	testSetUsername(t, bobService, "bobby")
+
+	charlieServiceEnv, charlieCloseFn := newTestTeamUser(t, env, "charlie@keys.pub", charlie, aliceService)
+	defer charlieCloseFn()
+	charlieService := charlieServiceEnv.service
+	testSetUsername(t, charlieService, "bob")
+
+	_, err = aliceService.ChannelInvite(ctx, &ChannelInviteRequest{Channel: channel.ID, Recipients: []string{charlie.ID().String()}})
+	require.NoError(t, err)
+	_, err = charlieService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	_, err = charlieService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "hi alice, it's bob"})
+	require.NoError(t, err)
+
+	msgs, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+	require.NoError(t, err)
+	last := msgs.Messages[len(msgs.Messages)-1]
+	require.Equal(t, []string{"hi alice, it's bob"}, last.Text)
+	require.Equal(t, "bob", last.Sender)
+	require.False(t, last.SenderVerified)
+	require.True(t, last.SenderKeyChanged)
+	for _, msg := range msgs.Messages {
+		if msg.ID == fromBob.Message.ID {
+			require.True(t, msg.SenderVerified)
+			require.False(t, msg.SenderKeyChanged)
+		}
+	}
+
+	users, err := aliceService.ChannelUsers(ctx, &ChannelUsersRequest{Channel: channel.ID})
+	require.NoError(t, err)
+	found := false
+	for _, u := range users.Users {
+		if u.ID == charlie.ID().String() {
+			found = true
+			require.Equal(t, "bob", u.Name)
+			require.False(t, u.Verified)
+			require.True(t, u.KeyChanged)
+		}
+	}
+	require.True(t, found)
+
+	sn, err = aliceService.SafetyNumber(ctx, &SafetyNumberRequest{User: charlie.ID().String()})
+	require.NoError(t, err)
+	require.False(t, sn.Verified)
+}
@@ -5,6 +5,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/keys-pub/keys"
 	"github.com/keys-pub/keys/dstore"
 	"github.com/keys-pub/keys/tsutil"
 	"github.com/pkg/errors"
@@ -118,3 +119,29 @@ func (s *service) dbKeys() ([]*Document, error) {
 	}
 	return out, nil
 }
+
+// channelDocuments returns the documents in a service db collection for a
+// channel, or all of them if channel is empty. The documents need a "channel"
+// field.
+func (s *service) channelDocuments(ctx context.Context, collection string, channel keys.ID) ([]*dstore.Document, error) {
+	docs, err := s.db.Documents(ctx, dstore.Path(collection))
+	if err != nil {
+		return nil, err
+	}
+	if channel == "" {
+		return docs, nil
+	}
+	out := []*dstore.Document{}
+	for _, doc := range docs {
+		var v struct {
+			Channel keys.ID `json:"channel"`
+		}
+		if err := doc.To(&v); err != nil {
+			return nil, err
+		}
+		if v.Channel == channel {
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
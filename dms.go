@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/getchill-app/http/api"
+	"github.com/getchill-app/messaging"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/pkg/errors"
+)
+
+// Direct messages are users channels with 2 users, named with dmName, so
+// both users would look for the same channel.
+const dmPrefix = "dm-"
+
+// directMessage is saved at /dms/{channel} so we know who a direct message
+// channel is with.
+type directMessage struct {
+	Channel keys.ID `json:"channel"`
+	User    keys.ID `json:"user"`
+}
+
+// notDirectMessage is saved at /notdms/{channel} for a users channel with a
+// direct message name, that isn't a direct message with us, so we don't look up
+// its users on every update. If the channel is renamed, we check again.
+type notDirectMessage struct {
+	Channel keys.ID `json:"channel"`
+	Name    string  `json:"name"`
+}
+
+// dmName returns the channel name for a direct message between 2 users.
+func dmName(a keys.ID, b keys.ID) string {
+	if b < a {
+		a, b = b, a
+	}
+	h := sha256.Sum256([]byte(a.String() + "," + b.String()))
+	return dmPrefix + hex.EncodeToString(h[:])[:12]
+}
+
+func isDMName(name string) bool {
+	return strings.HasPrefix(name, dmPrefix)
+}
+
+// directMessages returns direct messages by channel.
+func (s *service) directMessages(ctx context.Context) (map[keys.ID]*directMessage, error) {
+	docs, err := s.db.Documents(ctx, dstore.Path("dms"))
+	if err != nil {
+		return nil, err
+	}
+	out := map[keys.ID]*directMessage{}
+	for _, doc := range docs {
+		var dm directMessage
+		if err := doc.To(&dm); err != nil {
+			return nil, err
+		}
+		out[dm.Channel] = &dm
+	}
+	return out, nil
+}
+
+// checkDirectMessage saves who a direct message channel is with, if we
+// haven't already. Called when we update channels.
+func (s *service) checkDirectMessage(ctx context.Context, channel *messaging.Channel, channelKey *keys.EdX25519Key, account keys.ID) error {
+	if channel.Team != "" || !isDMName(channel.Name) {
+		return nil
+	}
+	exists, err := s.db.Exists(ctx, dstore.Path("dms", channelKey.ID()))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	notDM, err := s.notDirectMessage(ctx, channelKey.ID(), channel.Name)
+	if err != nil {
+		return err
+	}
+	if notDM {
+		return nil
+	}
+	users, err := s.client.ChannelUsers(ctx, channelKey)
+	if err != nil {
+		return err
+	}
+	if len(users) != 2 {
+		return s.saveNotDirectMessage(ctx, channelKey.ID(), channel.Name)
+	}
+	other := users[0]
+	if other == account {
+		other = users[1]
+	}
+	if dmName(account, other) != channel.Name || !isDMUsers(users, account, other) {
+		return s.saveNotDirectMessage(ctx, channelKey.ID(), channel.Name)
+	}
+	return s.saveDirectMessage(ctx, channelKey.ID(), other)
+}
+
+// notDirectMessage returns true if we checked the channel (with name) and it
+// isn't a direct message with us.
+func (s *service) notDirectMessage(ctx context.Context, cid keys.ID, name string) (bool, error) {
+	var notDM notDirectMessage
+	ok, err := s.db.Load(ctx, dstore.Path("notdms", cid), &notDM)
+	if err != nil {
+		return false, err
+	}
+	return ok && notDM.Name == name, nil
+}
+
+func (s *service) saveNotDirectMessage(ctx context.Context, cid keys.ID, name string) error {
+	logger.Debugf("Channel %s has a direct message name, but not the direct message users", cid)
+	notDM := &notDirectMessage{Channel: cid, Name: name}
+	return s.db.Set(ctx, dstore.Path("notdms", cid), dstore.From(notDM))
+}
+
+// isDMUsers returns true if the channel users are exactly account and user.
+// Anyone can name a channel with dmName, so we check who's in it before
+// treating it as a direct message.
+func isDMUsers(users []keys.ID, account keys.ID, user keys.ID) bool {
+	if len(users) != 2 {
+		return false
+	}
+	return (users[0] == account && users[1] == user) || (users[0] == user && users[1] == account)
+}
+
+func (s *service) saveDirectMessage(ctx context.Context, cid keys.ID, user keys.ID) error {
+	logger.Debugf("Direct message %s with %s", cid, user)
+	dm := &directMessage{Channel: cid, User: user}
+	return s.db.Set(ctx, dstore.Path("dms", cid), dstore.From(dm))
+}
+
+// checkNotDirectMessage returns an error if the channel is a direct message,
+// for changes that only make sense for other channels, like adding users.
+func (s *service) checkNotDirectMessage(ctx context.Context, cid keys.ID) error {
+	exists, err := s.db.Exists(ctx, dstore.Path("dms", cid))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.Errorf("can't add users to a direct message")
+	}
+	return nil
+}
+
+// findDirectMessage returns the direct message channel with user, if we have
+// it. Channels with the direct message name are only used if the users are
+// exactly us and them.
+func (s *service) findDirectMessage(ctx context.Context, account keys.ID, user keys.ID) (*messaging.Channel, error) {
+	channels, err := s.messenger.Channels()
+	if err != nil {
+		return nil, err
+	}
+	name := dmName(account, user)
+	// If we both created the channel at the same time, use the first one.
+	var found *messaging.Channel
+	for _, channel := range channels {
+		if channel.Team != "" || channel.Name != name {
+			continue
+		}
+		if found != nil && channel.ID > found.ID {
+			continue
+		}
+		ok, err := s.isDirectMessage(ctx, channel.ID, account, user)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			found = channel
+		}
+	}
+	return found, nil
+}
+
+// isDirectMessage returns true if the channel is a direct message with user,
+// saving it if we hadn't already.
+func (s *service) isDirectMessage(ctx context.Context, cid keys.ID, account keys.ID, user keys.ID) (bool, error) {
+	var dm directMessage
+	ok, err := s.db.Load(ctx, dstore.Path("dms", cid), &dm)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return dm.User == user, nil
+	}
+	name := dmName(account, user)
+	notDM, err := s.notDirectMessage(ctx, cid, name)
+	if err != nil {
+		return false, err
+	}
+	if notDM {
+		return false, nil
+	}
+	channelKey, err := s.keyring.Key(cid)
+	if err != nil {
+		return false, err
+	}
+	users, err := s.client.ChannelUsers(ctx, channelKey.AsEdX25519())
+	if err != nil {
+		return false, err
+	}
+	if !isDMUsers(users, account, user) {
+		return false, s.saveNotDirectMessage(ctx, cid, name)
+	}
+	if err := s.saveDirectMessage(ctx, cid, user); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DirectMessage (RPC) finds or creates a direct message channel with a user.
+func (s *service) DirectMessage(ctx context.Context, req *DirectMessageRequest) (*DirectMessageResponse, error) {
+	if req.User == "" {
+		return nil, errors.Errorf("no user specified")
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.findUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	if user == account.ID {
+		return nil, errors.Errorf("can't direct message yourself")
+	}
+	channel, err := s.findDirectMessage(ctx, account.ID, user)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		// Check if they created it and we haven't seen it yet.
+		if err := s.updateChannels(ctx); err != nil {
+			return nil, err
+		}
+		channel, err = s.findDirectMessage(ctx, account.ID, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if channel == nil {
+		channelKey := keys.GenerateEdX25519Key()
+		logger.Debugf("Creating direct message %s", channelKey.ID())
+		info := &api.ChannelInfo{Name: dmName(account.ID, user)}
+		if _, err := s.client.ChannelCreateWithUsers(ctx, channelKey, info, []keys.ID{account.ID, user}, account.AsEdX25519()); err != nil {
+			return nil, err
+		}
+		if err := s.saveDirectMessage(ctx, channelKey.ID(), user); err != nil {
+			return nil, err
+		}
+		if err := s.updateChannels(ctx); err != nil {
+			return nil, err
+		}
+		channel, err = s.messenger.Channel(channelKey.ID())
+		if err != nil {
+			return nil, err
+		}
+		if channel == nil {
+			return nil, errors.Errorf("direct message channel not found")
+		}
+	}
+
+	out := channelToRPC(channel)
+	if err := s.directMessageToRPC(ctx, out, user); err != nil {
+		return nil, err
+	}
+	return &DirectMessageResponse{Channel: out}, nil
+}
+
+// directMessageToRPC shows the channel with the other user's name.
+func (s *service) directMessageToRPC(ctx context.Context, c *Channel, user keys.ID) error {
+	name, err := s.userName(ctx, user)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = user.String()
+	}
+	c.Type = DirectChannelType
+	c.Name = name
+	c.User = user.String()
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMName(t *testing.T) {
+	alice := keys.ID("kex1alice")
+	bob := keys.ID("kex1bob")
+
+	name := dmName(alice, bob)
+	require.Equal(t, name, dmName(bob, alice))
+	require.NotEqual(t, name, dmName(alice, "kex1charlie"))
+	require.True(t, isDMName(name))
+	require.True(t, len(name) <= 16)
+	require.False(t, isDMName("general"))
+}
+
+func TestIsDMUsers(t *testing.T) {
+	alice := keys.ID("kex1alice")
+	bob := keys.ID("kex1bob")
+	charlie := keys.ID("kex1charlie")
+
+	require.True(t, isDMUsers([]keys.ID{alice, bob}, alice, bob))
+	require.True(t, isDMUsers([]keys.ID{bob, alice}, alice, bob))
+	require.False(t, isDMUsers([]keys.ID{alice, charlie}, alice, bob))
+	require.False(t, isDMUsers([]keys.ID{alice, bob, charlie}, alice, bob))
+	require.False(t, isDMUsers([]keys.ID{bob, bob}, alice, bob))
+	require.False(t, isDMUsers([]keys.ID{alice}, alice, bob))
+}
+
+func TestDirectMessage(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+
+	charlieServiceEnv, charlieCloseFn := newTestTeamUser(t, env, "charlie@keys.pub", charlie, aliceService)
+	defer charlieCloseFn()
+	charlieService := charlieServiceEnv.service
+
+	// Charlie creates a channel with alice and bob's direct message name,
+	// that charlie is also in.
+	fake := keys.GenerateEdX25519Key()
+	info := &api.ChannelInfo{Name: dmName(alice.ID(), bob.ID())}
+	_, err := charlieService.client.ChannelCreateWithUsers(ctx, fake, info, []keys.ID{alice.ID(), bob.ID(), charlie.ID()}, charlie)
+	require.NoError(t, err)
+
+	_, err = aliceService.DirectMessage(ctx, &DirectMessageRequest{User: alice.ID().String()})
+	require.EqualError(t, err, "can't direct message yourself")
+
+	// Alice doesn't use charlie's channel
+	dmAlice, err := aliceService.DirectMessage(ctx, &DirectMessageRequest{User: bob.ID().String()})
+	require.NoError(t, err)
+	require.NotEqual(t, fake.ID().String(), dmAlice.Channel.ID)
+	require.Equal(t, DirectChannelType, dmAlice.Channel.Type)
+	require.Equal(t, bob.ID().String(), dmAlice.Channel.User)
+
+	// Bob finds the one alice created
+	dmBob, err := bobService.DirectMessage(ctx, &DirectMessageRequest{User: alice.ID().String()})
+	require.NoError(t, err)
+	require.Equal(t, dmAlice.Channel.ID, dmBob.Channel.ID)
+	require.Equal(t, alice.ID().String(), dmBob.Channel.User)
+
+	dms, err := bobService.directMessages(ctx)
+	require.NoError(t, err)
+	require.Nil(t, dms[fake.ID()])
+
+	// Charlie's channel is remembered as not a direct message, so updates
+	// don't look up its users again.
+	notDM, err := bobService.notDirectMessage(ctx, fake.ID(), dmName(alice.ID(), bob.ID()))
+	require.NoError(t, err)
+	require.True(t, notDM)
+
+	// Same channel again
+	dmAlice2, err := aliceService.DirectMessage(ctx, &DirectMessageRequest{User: bob.ID().String()})
+	require.NoError(t, err)
+	require.Equal(t, dmAlice.Channel.ID, dmAlice2.Channel.ID)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/pkg/errors"
+)
+
+// draft is unsent text for a channel, at /drafts/{channel}.
+type draft struct {
+	Channel   keys.ID `json:"channel"`
+	Text      string  `json:"text"`
+	UpdatedAt int64   `json:"updatedAt"`
+}
+
+func (d *draft) toRPC() *Draft {
+	if d == nil {
+		return nil
+	}
+	return &Draft{
+		Channel:   d.Channel.String(),
+		Text:      d.Text,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+func (s *service) draft(ctx context.Context, channel keys.ID) (*draft, error) {
+	var d draft
+	ok, err := s.db.Load(ctx, dstore.Path("drafts", channel), &d)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+// draftChannels returns the channels with drafts.
+func (s *service) draftChannels(ctx context.Context) (map[keys.ID]bool, error) {
+	docs, err := s.db.Documents(ctx, dstore.Path("drafts"))
+	if err != nil {
+		return nil, err
+	}
+	out := map[keys.ID]bool{}
+	for _, doc := range docs {
+		var d draft
+		if err := doc.To(&d); err != nil {
+			return nil, err
+		}
+		out[d.Channel] = true
+	}
+	return out, nil
+}
+
+func (s *service) setDraft(ctx context.Context, channel keys.ID, text string) (*draft, error) {
+	if text == "" {
+		return nil, s.clearDraft(ctx, channel)
+	}
+	d := &draft{Channel: channel, Text: text, UpdatedAt: s.clock.NowMillis()}
+	if err := s.db.Set(ctx, dstore.Path("drafts", channel), dstore.From(d)); err != nil {
+		return nil, err
+	}
+	s.relay.Send(relayDraftEvent(channel, d))
+	return d, nil
+}
+
+func (s *service) clearDraft(ctx context.Context, channel keys.ID) error {
+	ok, err := s.db.Delete(ctx, dstore.Path("drafts", channel))
+	if err != nil {
+		return err
+	}
+	if ok {
+		s.relay.Send(relayDraftEvent(channel, nil))
+	}
+	return nil
+}
+
+// DraftSet (RPC) saves a draft for a channel.
+func (s *service) DraftSet(ctx context.Context, req *DraftSetRequest) (*DraftSetResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	// Don't trim, so we keep what they were typing.
+	d, err := s.setDraft(ctx, channel, req.Text)
+	if err != nil {
+		return nil, err
+	}
+	return &DraftSetResponse{Draft: d.toRPC()}, nil
+}
+
+// DraftGet (RPC) returns the draft for a channel, if any.
+func (s *service) DraftGet(ctx context.Context, req *DraftGetRequest) (*DraftGetResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	d, err := s.draft(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	return &DraftGetResponse{Draft: d.toRPC()}, nil
+}
+
+// DraftClear (RPC) removes the draft for a channel.
+func (s *service) DraftClear(ctx context.Context, req *DraftClearRequest) (*DraftClearResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if err := s.clearDraft(ctx, channel); err != nil {
+		return nil, err
+	}
+	return &DraftClearResponse{}, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrafts(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	channel := channelCreate.ID
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	get, err := aliceService.DraftGet(ctx, &DraftGetRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Nil(t, get.Draft)
+
+	_, err = aliceService.DraftSet(ctx, &DraftSetRequest{Channel: channel, Text: "hi al"})
+	require.NoError(t, err)
+	get, err = aliceService.DraftGet(ctx, &DraftGetRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Equal(t, "hi al", get.Draft.Text)
+
+	channels, err := aliceService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.True(t, channels.Channels[0].HasDraft)
+
+	// Sending clears the draft
+	_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel, Text: "hi alice"})
+	require.NoError(t, err)
+	get, err = aliceService.DraftGet(ctx, &DraftGetRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Nil(t, get.Draft)
+	channels, err = aliceService.Channels(ctx, &ChannelsRequest{})
+	require.NoError(t, err)
+	require.False(t, channels.Channels[0].HasDraft)
+
+	_, err = aliceService.DraftSet(ctx, &DraftSetRequest{Channel: channel, Text: "bye"})
+	require.NoError(t, err)
+	_, err = aliceService.DraftClear(ctx, &DraftClearRequest{Channel: channel})
+	require.NoError(t, err)
+	get, err = aliceService.DraftGet(ctx, &DraftGetRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Nil(t, get.Draft)
+}
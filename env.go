@@ -44,8 +44,9 @@ func NewEnv(appName string, build Build) (*Env, error) {
 const keysPubServerCfgKey = "keys-pub-server"
 const chillServerCfgKey = "chill-server"
 const portCfgKey = "port"
+const syncWorkersCfgKey = "sync-workers"
 
-var configKeys = []string{keysPubServerCfgKey, chillServerCfgKey, portCfgKey}
+var configKeys = []string{keysPubServerCfgKey, chillServerCfgKey, portCfgKey, syncWorkersCfgKey}
 
 // IsKey returns true if config key is recognized.
 func (e Env) IsKey(s string) bool {
@@ -72,6 +73,15 @@ func (e Env) ChillServerURL() string {
 	return e.Get(chillServerCfgKey, "https://getchill.app")
 }
 
+// SyncWorkers is the number of channels to sync at the same time.
+func (e Env) SyncWorkers() int {
+	n := e.GetInt(syncWorkersCfgKey, 4)
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
 // Build describes build flags.
 type Build struct {
 	Version        string
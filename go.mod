@@ -28,6 +28,28 @@ require (
 	google.golang.org/protobuf v1.26.0
 )
 
+// The sibling repos below need APIs that aren't in the required versions
+// yet, so those need releasing (and bumping here) first:
+//
+// getchill-app/messaging: Messenger.DeleteChannel (ChannelLeave).
+// getchill-app/messaging: Messenger.MessagesFrom, messages from an index in a
+// direction, with a limit (Messages paging).
+// getchill-app/http/api: MessageCommand.MessageEdit and MessageDelete (message
+// edit and delete).
+// getchill-app/http/api: Message.Parent (threads).
+// getchill-app/http/api: MessageCommand.MessageReaction (reactions).
+// getchill-app/http/client and http/server: Client.UsersLookup, looking up users
+// by kid in one request (user directory).
+// getchill-app/http/api: ChannelInfo.Topic, ClearDescription and ClearTopic
+// (channel topic, and clearing the description or topic).
+// getchill-app/messaging: Channel.Description (channel description).
+// getchill-app/messaging: SystemMessage, with Messenger.AddSystemMessage,
+// SystemMessages and DeleteSystemMessages (local system messages).
+// getchill-app/http/api: MessageCommand.ChannelRetention (disappearing
+// messages).
+// getchill-app/messaging: Messenger.DeleteMessages (disappearing messages).
+// getchill-app/http/api: MessageCommand.MessagePin (pinned messages).
+
 replace github.com/mutecomm/go-sqlcipher/v4 => github.com/getchill-app/go-sqlcipher/v4 v4.4.3-0.20210518231725-725caa68982f
 
 replace github.com/keys-pub/keys => ../../keys.pub/keys
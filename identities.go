@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/user"
+	"github.com/pkg/errors"
+)
+
+// identityRecord is what we last saw of a user's sigchain, saved at
+// /identities/{kid}, so we can warn if it changes. A warning stays until it's
+// dismissed (UserWarningDismiss), or replaced by a newer one.
+type identityRecord struct {
+	KID keys.ID `json:"kid"`
+	// Sigs are the (base64) statement signatures, in order.
+	Sigs []string `json:"sigs,omitempty"`
+	// UserSeq is the seq of the user statement we last verified.
+	UserSeq int `json:"userSeq,omitempty"`
+	// User is the name@service we last verified.
+	User      string `json:"user,omitempty"`
+	Warning   string `json:"warning,omitempty"`
+	WarningAt int64  `json:"warningAt,omitempty"`
+	CheckedAt int64  `json:"checkedAt,omitempty"`
+}
+
+func (s *service) identityRecord(ctx context.Context, kid keys.ID) (*identityRecord, error) {
+	var rec identityRecord
+	ok, err := s.db.Load(ctx, dstore.Path("identities", kid), &rec)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &rec, nil
+}
+
+// updateIdentity pulls the user's sigchain from keys.pub and verifies their
+// linked identity. If the sigchain changed in a way it shouldn't have, or the
+// identity was revoked, we save a warning.
+func (s *service) updateIdentity(ctx context.Context, kid keys.ID) error {
+	logger.Debugf("Update identity %s", kid)
+	resp, err := s.kclient.Sigchain(ctx, kid)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		logger.Debugf("No sigchain for %s", kid)
+		return nil
+	}
+	sc, err := resp.Sigchain()
+	if err != nil {
+		return err
+	}
+
+	rec, err := s.identityRecord(ctx, kid)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		rec = &identityRecord{KID: kid}
+	}
+	if warning := sigchainWarning(rec, sc.Statements()); warning != "" {
+		logger.Warningf("Identity warning for %s: %s", kid, warning)
+		rec.Warning = warning
+		rec.WarningAt = s.clock.NowMillis()
+	}
+
+	if err := s.scs.Save(sc); err != nil {
+		return err
+	}
+	result, err := s.users.Update(ctx, kid)
+	if err != nil {
+		return err
+	}
+
+	rec.Sigs = statementSigs(sc.Statements())
+	rec.UserSeq, rec.User = 0, ""
+	if result != nil && result.User != nil {
+		rec.UserSeq = result.User.Seq
+		rec.User = userString(result.User)
+	}
+	rec.CheckedAt = s.clock.NowMillis()
+	return s.db.Set(ctx, dstore.Path("identities", kid), dstore.From(rec))
+}
+
+func statementSigs(sts []*keys.Statement) []string {
+	sigs := make([]string, 0, len(sts))
+	for _, st := range sts {
+		sigs = append(sigs, base64.StdEncoding.EncodeToString(st.Sig))
+	}
+	return sigs
+}
+
+func userString(usr *user.User) string {
+	return fmt.Sprintf("%s@%s", usr.Name, usr.Service)
+}
+
+// sigchainWarning returns a warning if statements we've seen before were
+// changed or removed, or if the identity we verified was revoked.
+// Sigchains are append only, so anything else is expected.
+func sigchainWarning(rec *identityRecord, sts []*keys.Statement) string {
+	sigs := statementSigs(sts)
+	if len(sigs) < len(rec.Sigs) {
+		return "Sigchain was truncated"
+	}
+	for i, sig := range rec.Sigs {
+		if sigs[i] != sig {
+			return "Sigchain changed"
+		}
+	}
+	if rec.UserSeq != 0 {
+		for _, st := range sts[len(rec.Sigs):] {
+			if st.Revoke == rec.UserSeq {
+				return fmt.Sprintf("%s was revoked", rec.User)
+			}
+		}
+	}
+	return ""
+}
+
+// userIdentity returns the verified identity (if any) and warning for a user,
+// from what we have saved.
+func (s *service) userIdentity(ctx context.Context, kid keys.ID) (*Identity, string, error) {
+	result, err := s.users.Get(ctx, kid)
+	if err != nil {
+		return nil, "", err
+	}
+	rec, err := s.identityRecord(ctx, kid)
+	if err != nil {
+		return nil, "", err
+	}
+	warning := ""
+	if rec != nil {
+		warning = rec.Warning
+	}
+	return identityToRPC(result), warning, nil
+}
+
+func identityToRPC(result *user.Result) *Identity {
+	if result == nil || result.User == nil {
+		return nil
+	}
+	return &Identity{
+		Service:    result.User.Service,
+		Name:       result.User.Name,
+		URL:        result.User.URL,
+		Status:     string(result.Status),
+		Error:      result.Err,
+		VerifiedAt: result.VerifiedAt,
+		Timestamp:  result.Timestamp,
+	}
+}
+
+// UserInfo (RPC) returns a user with their verified identity.
+func (s *service) UserInfo(ctx context.Context, req *UserInfoRequest) (*UserInfoResponse, error) {
+	if req.User == "" {
+		return nil, errors.Errorf("no user specified")
+	}
+	kid, err := s.findUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	if req.Update {
+		if err := s.updateIdentity(ctx, kid); err != nil {
+			return nil, err
+		}
+	}
+	users, err := s.lookupUsers(ctx, []keys.ID{kid})
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userToRPC(ctx, users[kid])
+	if err != nil {
+		return nil, err
+	}
+	return &UserInfoResponse{User: user}, nil
+}
+
+func (s *service) userToRPC(ctx context.Context, cu *cachedUser) (*User, error) {
+	result, err := s.users.Get(ctx, cu.KID)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := s.identityRecord(ctx, cu.KID)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{
+		KID:       cu.KID.String(),
+		Username:  cu.Username,
+		Missing:   cu.Missing,
+		UpdatedAt: cu.Timestamp,
+		Identity:  identityToRPC(result),
+	}
+	if rec != nil {
+		user.Warning = rec.Warning
+		user.WarningAt = rec.WarningAt
+	}
+	return user, nil
+}
+
+// UserWarningDismiss (RPC) clears a user's warning. We keep the sigchain we
+// warned about, so we only warn again if it changes again.
+func (s *service) UserWarningDismiss(ctx context.Context, req *UserWarningDismissRequest) (*UserWarningDismissResponse, error) {
+	if req.User == "" {
+		return nil, errors.Errorf("no user specified")
+	}
+	kid, err := s.findUser(ctx, req.User)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := s.identityRecord(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil || rec.Warning == "" {
+		return nil, errors.Errorf("no warning")
+	}
+	rec.Warning = ""
+	rec.WarningAt = 0
+	if err := s.db.Set(ctx, dstore.Path("identities", kid), dstore.From(rec)); err != nil {
+		return nil, err
+	}
+	return &UserWarningDismissResponse{}, nil
+}
+
+// findUser returns the kid for a KID or username.
+func (s *service) findUser(ctx context.Context, user string) (keys.ID, error) {
+	if kid, err := keys.ParseID(user); err == nil {
+		return kid, nil
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return "", err
+	}
+	usr, err := s.client.UserLookup(ctx, "username", user, account.AsEdX25519())
+	if err != nil {
+		return "", err
+	}
+	if usr == nil {
+		return "", errors.Errorf("user not found")
+	}
+	return usr.KID, nil
+}
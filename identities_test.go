@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigchainWarning(t *testing.T) {
+	st1 := &keys.Statement{Seq: 1, Sig: []byte("sig1")}
+	st2 := &keys.Statement{Seq: 2, Sig: []byte("sig2"), Type: "user"}
+	st3 := &keys.Statement{Seq: 3, Sig: []byte("sig3")}
+
+	// New
+	rec := &identityRecord{KID: "kid1"}
+	require.Equal(t, "", sigchainWarning(rec, []*keys.Statement{st1, st2}))
+
+	// Appended
+	rec = &identityRecord{KID: "kid1", Sigs: statementSigs([]*keys.Statement{st1, st2}), UserSeq: 2, User: "alice@github"}
+	require.Equal(t, "", sigchainWarning(rec, []*keys.Statement{st1, st2, st3}))
+
+	// Truncated
+	require.Equal(t, "Sigchain was truncated", sigchainWarning(rec, []*keys.Statement{st1}))
+
+	// Changed
+	st2b := &keys.Statement{Seq: 2, Sig: []byte("sig2b")}
+	require.Equal(t, "Sigchain changed", sigchainWarning(rec, []*keys.Statement{st1, st2b}))
+
+	// Revoked
+	revoke := &keys.Statement{Seq: 3, Sig: []byte("sig3r"), Type: "revoke", Revoke: 2}
+	require.Equal(t, "alice@github was revoked", sigchainWarning(rec, []*keys.Statement{st1, st2, revoke}))
+}
+
+func TestUserInfo(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	testSetUsername(t, bobService, "bob")
+
+	// Bob's sigchain on keys.pub
+	sc := keys.NewSigchain(bob.ID())
+	for _, b := range []string{"test1", "test2"} {
+		st, err := keys.NewSigchainStatement(sc, []byte(b), bob, "test", env.clock.Now())
+		require.NoError(t, err)
+		require.NoError(t, sc.Add(st))
+		require.NoError(t, aliceService.kclient.SigchainSave(ctx, st))
+	}
+
+	_, err := aliceService.UserInfo(ctx, &UserInfoRequest{})
+	require.EqualError(t, err, "no user specified")
+
+	info, err := aliceService.UserInfo(ctx, &UserInfoRequest{User: "bob", Update: true})
+	require.NoError(t, err)
+	require.Equal(t, bob.ID().String(), info.User.KID)
+	require.Equal(t, "bob", info.User.Username)
+	require.Nil(t, info.User.Identity)
+	require.Empty(t, info.User.Warning)
+
+	_, err = aliceService.UserWarningDismiss(ctx, &UserWarningDismissRequest{User: "bob"})
+	require.EqualError(t, err, "no warning")
+
+	// If what we saw before changes, we warn
+	rec, err := aliceService.identityRecord(ctx, bob.ID())
+	require.NoError(t, err)
+	require.Equal(t, 2, len(rec.Sigs))
+	rec.Sigs[0] = "changed"
+	require.NoError(t, aliceService.db.Set(ctx, dstore.Path("identities", bob.ID()), dstore.From(rec)))
+
+	info, err = aliceService.UserInfo(ctx, &UserInfoRequest{User: bob.ID().String(), Update: true})
+	require.NoError(t, err)
+	require.Equal(t, "Sigchain changed", info.User.Warning)
+	require.NotEmpty(t, info.User.WarningAt)
+
+	// Warning stays until dismissed
+	info, err = aliceService.UserInfo(ctx, &UserInfoRequest{User: "bob", Update: true})
+	require.NoError(t, err)
+	require.Equal(t, "Sigchain changed", info.User.Warning)
+	users, err := aliceService.Users(ctx, &UsersRequest{KIDs: []string{bob.ID().String()}})
+	require.NoError(t, err)
+	require.Equal(t, "Sigchain changed", users.Users[0].Warning)
+
+	_, err = aliceService.UserWarningDismiss(ctx, &UserWarningDismissRequest{User: "bob"})
+	require.NoError(t, err)
+	info, err = aliceService.UserInfo(ctx, &UserInfoRequest{User: "bob", Update: true})
+	require.NoError(t, err)
+	require.Empty(t, info.User.Warning)
+	require.Empty(t, info.User.WarningAt)
+
+	// Revoking the identity we verified
+	rec, err = aliceService.identityRecord(ctx, bob.ID())
+	require.NoError(t, err)
+	rec.UserSeq, rec.User = 1, "bob@github"
+	require.NoError(t, aliceService.db.Set(ctx, dstore.Path("identities", bob.ID()), dstore.From(rec)))
+	revoke, err := sc.Revoke(1, bob)
+	require.NoError(t, err)
+	require.NoError(t, aliceService.kclient.SigchainSave(ctx, revoke))
+
+	info, err = aliceService.UserInfo(ctx, &UserInfoRequest{User: "bob", Update: true})
+	require.NoError(t, err)
+	require.Equal(t, "bob@github was revoked", info.User.Warning)
+}
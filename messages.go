@@ -21,6 +21,10 @@ func (s *service) MessagePrepare(ctx context.Context, req *MessagePrepareRequest
 	if req.Channel == "" {
 		return nil, errors.Errorf("no channel specified")
 	}
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
 
 	account, err := s.account(true)
 	if err != nil {
@@ -41,6 +45,14 @@ func (s *service) MessagePrepare(ctx context.Context, req *MessagePrepareRequest
 		Status:    MessagePending,
 		CreatedAt: tsutil.Millis(s.clock.Now()),
 	}
+	info, err := s.channelInfo(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	if info.Retention > 0 {
+		message.ExpiresAt = message.CreatedAt + info.Retention
+		message.ExpiresIn = info.Retention
+	}
 
 	return &MessagePrepareResponse{
 		Message: message,
@@ -59,10 +71,19 @@ func (s *service) MessageSend(ctx context.Context, req *MessageSendRequest) (*Me
 
 	text := processText(req.Text)
 	if strings.HasPrefix(text, "/") {
-		msg, err := s.command(ctx, text, req.Channel)
+		channel, err := keys.ParseID(req.Channel)
 		if err != nil {
 			return nil, err
 		}
+		msg, err := s.command(ctx, text, channel)
+		if err != nil {
+			// Show the error in the channel.
+			logger.Infof("Command failed: %v", err)
+			msg, err = s.addSystemMessage(ctx, channel, err.Error())
+			if err != nil {
+				return nil, err
+			}
+		}
 		return &MessageSendResponse{Message: msg}, nil
 	}
 
@@ -75,10 +96,6 @@ func (s *service) MessageSend(ctx context.Context, req *MessageSendRequest) (*Me
 	if err != nil {
 		return nil, err
 	}
-	channelKey, err := s.keyring.Key(channel)
-	if err != nil {
-		return nil, err
-	}
 
 	// TODO: Prev
 	msg := api.NewMessage(channel, account.ID).
@@ -87,31 +104,177 @@ func (s *service) MessageSend(ctx context.Context, req *MessageSendRequest) (*Me
 	if req.ID != "" {
 		msg.ID = req.ID
 	}
+	if req.Parent != "" {
+		parent, err := s.threadParent(channel, req.Parent)
+		if err != nil {
+			return nil, err
+		}
+		msg.Parent = parent
+	}
 
-	if err := s.client.SendMessage(ctx, msg, channelKey.AsEdX25519(), account.AsEdX25519()); err != nil {
-		return nil, err
+	// If we fail to send, save to the outbox to retry later.
+	status := MessageSent
+	if err := s.sendMessage(ctx, msg); err != nil {
+		logger.Warningf("Failed to send message %s: %v", msg.ID, err)
+		if _, err := s.outboxAdd(ctx, msg, err); err != nil {
+			return nil, err
+		}
+		status = MessagePending
+	}
+	// Drafts are for the channel, not threads.
+	if msg.Parent == "" {
+		if err := s.clearDraft(ctx, channel); err != nil {
+			return nil, err
+		}
 	}
 
 	out, err := s.messageToRPC(ctx, msg)
 	if err != nil {
 		return nil, err
 	}
+	out.Status = status
 
 	return &MessageSendResponse{
 		Message: out,
 	}, nil
 }
 
+// MessageEdit (RPC) changes the text of a message we sent.
+func (s *service) MessageEdit(ctx context.Context, req *MessageEditRequest) (*MessageEditResponse, error) {
+	text := processText(req.Text)
+	if text == "" {
+		return nil, errors.Errorf("no text specified")
+	}
+	orig, account, err := s.senderMessage(req.Channel, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := api.NewMessage(orig.Channel, account).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{
+		MessageEdit: &api.MessageEdit{ID: orig.ID, Text: text},
+	}
+	if err := s.sendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	out, err := s.messageToRPC(ctx, orig)
+	if err != nil {
+		return nil, err
+	}
+	out.Text = []string{text}
+	out.Edited = true
+	return &MessageEditResponse{Message: out}, nil
+}
+
+// MessageDelete (RPC) removes a message we sent.
+func (s *service) MessageDelete(ctx context.Context, req *MessageDeleteRequest) (*MessageDeleteResponse, error) {
+	orig, account, err := s.senderMessage(req.Channel, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := api.NewMessage(orig.Channel, account).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{
+		MessageDelete: &api.MessageDelete{ID: orig.ID},
+	}
+	if err := s.sendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	return &MessageDeleteResponse{}, nil
+}
+
+// MessageThread (RPC) lists replies to a message.
+func (s *service) MessageThread(ctx context.Context, req *MessageThreadRequest) (*MessageThreadResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	agg, err := s.channelAggregate(channel)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.NowMillis()
+	parent := agg.message(req.ID)
+	if parent == nil || agg.expired(parent.ID, now) {
+		return nil, errors.Errorf("message not found")
+	}
+	out, err := s.messagesToRPC(ctx, []*api.Message{parent}, agg)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := s.messagesToRPC(ctx, agg.unexpired(agg.thread(parent.ID), now), agg)
+	if err != nil {
+		return nil, err
+	}
+	outbox, err := s.outboxMessagesToRPC(ctx, channel, parent.ID)
+	if err != nil {
+		return nil, err
+	}
+	replies = append(replies, outbox...)
+
+	return &MessageThreadResponse{
+		Message: out[0],
+		Replies: replies,
+	}, nil
+}
+
+// threadParent returns the parent ID to use for a reply to id. Threads are
+// only one level deep, so replying to a reply, replies to its parent.
+func (s *service) threadParent(channel keys.ID, id string) (string, error) {
+	parent, err := s.findMessage(channel, id)
+	if err != nil {
+		return "", err
+	}
+	if parent == nil {
+		return "", errors.Errorf("parent message not found")
+	}
+	if parent.Parent != "" {
+		return parent.Parent, nil
+	}
+	return parent.ID, nil
+}
+
+// senderMessage returns the message with id, if we are the sender.
+func (s *service) senderMessage(channel string, id string) (*api.Message, keys.ID, error) {
+	cid, err := keys.ParseID(channel)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "invalid channel")
+	}
+	if id == "" {
+		return nil, "", errors.Errorf("no message id specified")
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, "", err
+	}
+	msg, err := s.findMessage(cid, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if msg == nil {
+		return nil, "", errors.Errorf("message not found")
+	}
+	if msg.Sender != account.ID {
+		return nil, "", errors.Errorf("only the sender can change a message")
+	}
+	return msg, account.ID, nil
+}
+
 func (s *service) PullMessages(ctx context.Context, cid keys.ID) error {
 	channel, err := s.messenger.Channel(cid)
 	if err != nil {
 		return err
 	}
+	if channel == nil {
+		return errors.Errorf("channel not found")
+	}
 	channelKey, err := s.keyring.Key(cid)
 	if err != nil {
 		return err
 	}
 	index := channel.MessageIndex
+	pulled := []*api.Message{}
 	for {
 		logger.Debugf("Pulling messages idx=%d for %s", index, cid)
 		msgs, err := s.client.Messages(ctx, channelKey.AsEdX25519(), index)
@@ -126,12 +289,19 @@ func (s *service) PullMessages(ctx context.Context, cid keys.ID) error {
 		if err := s.messenger.AddMessages(cid, msgs.Messages); err != nil {
 			return err
 		}
+		pulled = append(pulled, msgs.Messages...)
 		if !msgs.Truncated {
 			break
 		}
 		index = msgs.Index
 	}
-	return nil
+	if err := s.updateChannelInfo(ctx, cid); err != nil {
+		return err
+	}
+	if err := s.updateUnread(ctx, cid, pulled); err != nil {
+		return err
+	}
+	return s.indexChannel(ctx, cid)
 }
 
 // Messages (RPC) lists messages.
@@ -147,17 +317,59 @@ func (s *service) Messages(ctx context.Context, req *MessagesRequest) (*Messages
 		}
 	}
 
-	messages, err := s.messenger.Messages(channel)
+	opts := MessagesOpts{
+		Index: req.Index,
+		Order: directionFromRPC(req.Direction),
+		Limit: int(req.Limit),
+	}
+	page, err := s.messages(channel, opts)
 	if err != nil {
 		return nil, err
 	}
-	out, err := s.messagesToRPC(ctx, messages)
+	out, err := s.messagesToRPC(ctx, page.Messages, page.aggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	sms, err := s.systemMessages(channel)
 	if err != nil {
 		return nil, err
 	}
+	// The message we listed from is the last message of the previous page.
+	var prev *api.Message
+	if opts.Index != 0 {
+		msgs, err := s.messenger.MessagesFrom(channel, opts.Index-1, events.Ascending, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(msgs) > 0 {
+			prev = msgs[0]
+		}
+	}
+	descending := opts.Order == events.Descending
+	from, to := pageTimeRange(out, descending, prev, page.Next != 0)
+	out = withSystemMessages(out, sms, descending, from, to)
+	latest := (!descending && page.Next == 0) || (descending && opts.Index == 0)
+
+	// Include pending (or failed) messages from the outbox with the latest
+	// messages (the last page ascending, or the first page descending).
+	if latest {
+		outbox, err := s.outboxMessagesToRPC(ctx, channel, "")
+		if err != nil {
+			return nil, err
+		}
+		if descending {
+			reverseMessages(outbox)
+			out = append(outbox, out...)
+		} else {
+			out = append(out, outbox...)
+		}
+	}
 
 	return &MessagesResponse{
 		Messages: out,
+		Next:     page.Next,
+		Prev:     page.Prev,
 	}, nil
 }
 
@@ -171,13 +383,191 @@ type MessagesOpts struct {
 	Limit int
 }
 
-func (s *service) messagesToRPC(ctx context.Context, msgs []*api.Message) ([]*Message, error) {
+func directionFromRPC(dir Direction) events.Direction {
+	switch dir {
+	case Descending:
+		return events.Descending
+	default:
+		return events.Ascending
+	}
+}
+
+type messagesPage struct {
+	Messages []*api.Message
+	// Next index to continue listing in the same direction.
+	Next int64
+	// Prev index to list in the opposite direction.
+	Prev int64
+
+	aggregate *messageAggregate
+}
+
+func (s *service) messages(channel keys.ID, opts MessagesOpts) (*messagesPage, error) {
+	agg, err := s.channelAggregate(channel)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.NowMillis()
+	fetch := func(index int64, limit int) ([]*api.Message, error) {
+		return s.messenger.MessagesFrom(channel, index, opts.Order, limit)
+	}
+	keep := func(msg *api.Message) bool {
+		return visible(msg) && !agg.expired(msg.ID, now)
+	}
+	page, err := pageMessages(fetch, keep, opts)
+	if err != nil {
+		return nil, err
+	}
+	page.aggregate = agg
+	return page, nil
+}
+
+// channelAggregate returns the aggregate for a channel, adding messages since
+// we last looked.
+func (s *service) channelAggregate(channel keys.ID) (*messageAggregate, error) {
+	s.aggregates.Lock()
+	defer s.aggregates.Unlock()
+	agg := s.aggregates.channels[channel]
+	var index int64
+	if agg != nil {
+		index = agg.index
+	}
+	msgs, err := s.messenger.MessagesFrom(channel, index, events.Ascending, 0)
+	if err != nil {
+		return nil, err
+	}
+	if agg == nil {
+		agg = newMessageAggregate()
+		s.aggregates.channels[channel] = agg
+	}
+	agg.add(msgs)
+	return agg, nil
+}
+
+// findMessage returns message with id from the channel, or nil if not found.
+func (s *service) findMessage(channel keys.ID, id string) (*api.Message, error) {
+	agg, err := s.channelAggregate(channel)
+	if err != nil {
+		return nil, err
+	}
+	return agg.message(id), nil
+}
+
+// pageMessages returns a page of messages to keep, from opts.Index
+// (exclusive) in the opts.Order direction. Messages are fetched in batches
+// (from index, ordered by remote index in the direction) until the page is
+// full, since some are skipped.
+func pageMessages(fetch func(index int64, limit int) ([]*api.Message, error), keep func(msg *api.Message) bool, opts MessagesOpts) (*messagesPage, error) {
+	// One more than the limit, to know if there is a next page.
+	batch := 0
+	if opts.Limit > 0 {
+		batch = opts.Limit + 1
+	}
+	out := []*api.Message{}
+	index := opts.Index
+	for {
+		msgs, err := fetch(index, batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if keep(msg) {
+				out = append(out, msg)
+			}
+		}
+		if batch == 0 || len(msgs) < batch || len(out) > opts.Limit {
+			break
+		}
+		index = msgs[len(msgs)-1].RemoteIndex
+	}
+
+	page := &messagesPage{Messages: out}
+	if opts.Limit > 0 && len(out) > opts.Limit {
+		page.Messages = out[:opts.Limit]
+		page.Next = page.Messages[opts.Limit-1].RemoteIndex
+	}
+	// If we listed from an index, there are messages in the other direction.
+	if opts.Index != 0 && len(page.Messages) > 0 {
+		page.Prev = page.Messages[0].RemoteIndex
+	}
+	return page, nil
+}
+
+func (s *service) messagesToRPC(ctx context.Context, msgs []*api.Message, agg *messageAggregate) ([]*Message, error) {
+	account, err := s.account(false)
+	if err != nil {
+		return nil, err
+	}
+	// Resolve senders (and reactions, pins) as a batch, messageToRPC then uses
+	// the cache.
+	kids := []keys.ID{}
+	for _, msg := range msgs {
+		kids = append(kids, msg.Sender)
+		for _, r := range agg.messageReactions(msg.ID) {
+			kids = append(kids, r.senders...)
+		}
+		if p := agg.pinned(msg.ID); p != nil {
+			kids = append(kids, p.sender)
+		}
+	}
+	if _, err := s.userNames(ctx, kids); err != nil {
+		return nil, err
+	}
+	type senderIdentity struct {
+		identity   *Identity
+		warning    string
+		verified   bool
+		keyChanged bool
+	}
+	identities := map[keys.ID]*senderIdentity{}
+	saved, err := s.savedIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.NowMillis()
 	out := make([]*Message, 0, len(msgs))
 	for _, msg := range msgs {
 		m, err := s.messageToRPC(ctx, msg)
 		if err != nil {
 			return nil, err
 		}
+		si, ok := identities[msg.Sender]
+		if !ok {
+			identity, warning, err := s.userIdentity(ctx, msg.Sender)
+			if err != nil {
+				return nil, err
+			}
+			verified, keyChanged, err := s.contactStatus(ctx, msg.Sender, m.Sender)
+			if err != nil {
+				return nil, err
+			}
+			si = &senderIdentity{identity: identity, warning: warning, verified: verified, keyChanged: keyChanged}
+			identities[msg.Sender] = si
+		}
+		m.SenderIdentity = si.identity
+		m.SenderWarning = si.warning
+		m.SenderVerified = si.verified
+		m.SenderKeyChanged = si.keyChanged
+		agg.apply(m, now)
+		setExpiresIn(m, now)
+		m.Saved = saved[msg.ID]
+		if p := agg.pinned(msg.ID); p != nil {
+			name, err := s.userName(ctx, p.sender)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = p.sender.String()
+			}
+			m.PinnedBy = name
+		}
+		if !m.Deleted {
+			reactions, err := s.reactionsToRPC(ctx, agg.messageReactions(msg.ID), account)
+			if err != nil {
+				return nil, err
+			}
+			m.Reactions = reactions
+		}
 		out = append(out, m)
 	}
 	return out, nil
@@ -205,6 +595,7 @@ func (s *service) messageToRPC(ctx context.Context, msg *api.Message) (*Message,
 		Text:      text,
 		Sender:    sender,
 		CreatedAt: msg.Timestamp,
+		Parent:    msg.Parent,
 	}, nil
 }
 
@@ -221,14 +612,33 @@ func (s *service) messageText(ctx context.Context, msg *api.Message, sender stri
 		if msg.Command.ChannelInfo != nil && msg.Command.ChannelInfo.Description != "" {
 			texts = append(texts, fmt.Sprintf("Set the channel description to %s", msg.Command.ChannelInfo.Description))
 		}
+		if msg.Command.ChannelInfo != nil && msg.Command.ChannelInfo.ClearDescription {
+			texts = append(texts, "Cleared the channel description")
+		}
+		if msg.Command.ChannelInfo != nil && msg.Command.ChannelInfo.Topic != "" {
+			texts = append(texts, fmt.Sprintf("Set the channel topic to %s", msg.Command.ChannelInfo.Topic))
+		}
+		if msg.Command.ChannelInfo != nil && msg.Command.ChannelInfo.ClearTopic {
+			texts = append(texts, "Cleared the channel topic")
+		}
+		if retention := msg.Command.ChannelRetention; retention != nil {
+			if retention.TTL > 0 {
+				texts = append(texts, fmt.Sprintf("Set messages to disappear after %s", retentionString(retention.TTL)))
+			} else {
+				texts = append(texts, "Turned off disappearing messages")
+			}
+		}
 
-		// for _, invite := range msg.Command.ChannelInvites {
-		// 	recipient, err := s.resolveKey(ctx, invite.Recipient)
-		// 	if err != nil {
-		// 		return nil, err
-		// 	}
-		// 	texts = append(texts, fmt.Sprintf("%s invited %s", sender.userName(), recipient.userName()))
-		// }
+		for _, invite := range msg.Command.ChannelInvites {
+			recipient, err := s.userName(ctx, invite.Recipient)
+			if err != nil {
+				return nil, err
+			}
+			if recipient == "" {
+				recipient = invite.Recipient.String()
+			}
+			texts = append(texts, fmt.Sprintf("%s invited %s", sender, recipient))
+		}
 	}
 
 	return texts, nil
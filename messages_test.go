@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore/events"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessagesWithIndexes(indexes ...int64) []*api.Message {
+	msgs := []*api.Message{}
+	for _, idx := range indexes {
+		msgs = append(msgs, &api.Message{RemoteIndex: idx})
+	}
+	return msgs
+}
+
+// testPageMessages pages msgs (sorted by remote index), fetching like the
+// messenger does.
+func testPageMessages(t *testing.T, msgs []*api.Message, opts MessagesOpts, keep func(msg *api.Message) bool) *messagesPage {
+	fetch := func(index int64, limit int) ([]*api.Message, error) {
+		out := []*api.Message{}
+		if opts.Order == events.Descending {
+			for i := len(msgs) - 1; i >= 0; i-- {
+				if index == 0 || msgs[i].RemoteIndex < index {
+					out = append(out, msgs[i])
+				}
+			}
+		} else {
+			for _, msg := range msgs {
+				if msg.RemoteIndex > index {
+					out = append(out, msg)
+				}
+			}
+		}
+		if limit > 0 && len(out) > limit {
+			out = out[:limit]
+		}
+		return out, nil
+	}
+	if keep == nil {
+		keep = func(msg *api.Message) bool { return true }
+	}
+	page, err := pageMessages(fetch, keep, opts)
+	require.NoError(t, err)
+	return page
+}
+
+func pageIndexes(page *messagesPage) []int64 {
+	out := []int64{}
+	for _, msg := range page.Messages {
+		out = append(out, msg.RemoteIndex)
+	}
+	return out
+}
+
+func TestPageMessages(t *testing.T) {
+	msgs := testMessagesWithIndexes(1, 2, 3, 4, 5)
+
+	// All
+	page := testPageMessages(t, msgs, MessagesOpts{}, nil)
+	require.Equal(t, []int64{1, 2, 3, 4, 5}, pageIndexes(page))
+	require.Equal(t, int64(0), page.Next)
+	require.Equal(t, int64(0), page.Prev)
+
+	// Ascending
+	page = testPageMessages(t, msgs, MessagesOpts{Limit: 2}, nil)
+	require.Equal(t, []int64{1, 2}, pageIndexes(page))
+	require.Equal(t, int64(2), page.Next)
+	require.Equal(t, int64(0), page.Prev)
+
+	page = testPageMessages(t, msgs, MessagesOpts{Index: page.Next, Limit: 2}, nil)
+	require.Equal(t, []int64{3, 4}, pageIndexes(page))
+	require.Equal(t, int64(4), page.Next)
+	require.Equal(t, int64(3), page.Prev)
+
+	page = testPageMessages(t, msgs, MessagesOpts{Index: page.Next, Limit: 2}, nil)
+	require.Equal(t, []int64{5}, pageIndexes(page))
+	require.Equal(t, int64(0), page.Next)
+	require.Equal(t, int64(5), page.Prev)
+
+	// Descending
+	page = testPageMessages(t, msgs, MessagesOpts{Order: events.Descending, Limit: 2}, nil)
+	require.Equal(t, []int64{5, 4}, pageIndexes(page))
+	require.Equal(t, int64(4), page.Next)
+	require.Equal(t, int64(0), page.Prev)
+
+	page = testPageMessages(t, msgs, MessagesOpts{Order: events.Descending, Index: page.Next, Limit: 2}, nil)
+	require.Equal(t, []int64{3, 2}, pageIndexes(page))
+	require.Equal(t, int64(2), page.Next)
+	require.Equal(t, int64(3), page.Prev)
+
+	page = testPageMessages(t, msgs, MessagesOpts{Order: events.Descending, Index: page.Next, Limit: 2}, nil)
+	require.Equal(t, []int64{1}, pageIndexes(page))
+	require.Equal(t, int64(0), page.Next)
+	require.Equal(t, int64(1), page.Prev)
+
+	// Empty
+	page = testPageMessages(t, []*api.Message{}, MessagesOpts{Order: events.Descending, Limit: 2}, nil)
+	require.Equal(t, []int64{}, pageIndexes(page))
+	require.Equal(t, int64(0), page.Next)
+	require.Equal(t, int64(0), page.Prev)
+
+	// Skipped messages (for example, commands) don't count towards the limit.
+	msgs = testMessagesWithIndexes(1, 2, 3, 4, 5, 6, 7)
+	odd := func(msg *api.Message) bool { return msg.RemoteIndex%2 == 1 }
+	page = testPageMessages(t, msgs, MessagesOpts{Limit: 2}, odd)
+	require.Equal(t, []int64{1, 3}, pageIndexes(page))
+	require.Equal(t, int64(3), page.Next)
+	page = testPageMessages(t, msgs, MessagesOpts{Index: page.Next, Limit: 2}, odd)
+	require.Equal(t, []int64{5, 7}, pageIndexes(page))
+	require.Equal(t, int64(0), page.Next)
+	require.Equal(t, int64(5), page.Prev)
+	page = testPageMessages(t, msgs, MessagesOpts{Order: events.Descending, Limit: 3}, odd)
+	require.Equal(t, []int64{7, 5, 3}, pageIndexes(page))
+	require.Equal(t, int64(3), page.Next)
+}
+
+func TestMessagesPending(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	channel := channelCreate.ID
+	cid, err := keys.ParseID(channel)
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	for _, text := range []string{"one", "two", "three"} {
+		_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel, Text: text})
+		require.NoError(t, err)
+	}
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+	msg := api.NewMessage(cid, alice.ID()).WithText("four").WithTimestamp(env.clock.NowMillis())
+	_, err = aliceService.outboxAdd(ctx, msg, errors.Errorf("offline"))
+	require.NoError(t, err)
+
+	texts := func(msgs []*Message) []string {
+		out := []string{}
+		for _, m := range msgs {
+			out = append(out, m.Text...)
+		}
+		return out
+	}
+
+	// Newest page first, with the pending message, though there is more
+	// history.
+	page, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Direction: Descending, Limit: 2})
+	require.NoError(t, err)
+	require.NotEqual(t, int64(0), page.Next)
+	require.Equal(t, []string{"four", "three", "two"}, texts(page.Messages))
+	require.Equal(t, MessagePending, page.Messages[0].Status)
+	page, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Direction: Descending, Limit: 2, Index: page.Next})
+	require.NoError(t, err)
+	require.Equal(t, []string{"one"}, texts(page.Messages))
+
+	// Ascending, the pending message is on the last page.
+	page, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"one", "two"}, texts(page.Messages))
+	page, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Limit: 2, Index: page.Next})
+	require.NoError(t, err)
+	require.Equal(t, []string{"three", "four"}, texts(page.Messages))
+}
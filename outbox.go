@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/pkg/errors"
+)
+
+// outboxMaxAttempts is the number of times we try to send a message before
+// marking it as failed.
+const outboxMaxAttempts = 5
+
+// outboxMessage is a message that failed to send, at /outbox/{id}, so we can
+// retry.
+type outboxMessage struct {
+	ID      string        `json:"id"`
+	Channel keys.ID       `json:"channel"`
+	Status  MessageStatus `json:"status"`
+	// Message is the JSON encoded api.Message.
+	Message     []byte `json:"msg"`
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error,omitempty"`
+	Timestamp   int64  `json:"ts"`
+	NextAttempt int64  `json:"next,omitempty"`
+}
+
+func (o *outboxMessage) message() (*api.Message, error) {
+	var msg api.Message
+	if err := json.Unmarshal(o.Message, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// outboxSending is the outbox messages being sent, so a retry (on relay
+// connect or ping) and a resend don't send the same message at the same time.
+type outboxSending struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+func newOutboxSending() *outboxSending {
+	return &outboxSending{ids: map[string]bool{}}
+}
+
+// start returns false if the message is already being sent.
+func (o *outboxSending) start(id string) bool {
+	o.Lock()
+	defer o.Unlock()
+	if o.ids[id] {
+		return false
+	}
+	o.ids[id] = true
+	return true
+}
+
+func (o *outboxSending) end(id string) {
+	o.Lock()
+	defer o.Unlock()
+	delete(o.ids, id)
+}
+
+// outboxBackoff returns how long to wait before the next send attempt.
+func outboxBackoff(attempts int) time.Duration {
+	max := 5 * time.Minute
+	if attempts > 10 {
+		return max
+	}
+	dt := time.Duration(1<<uint(attempts)) * time.Second
+	if dt > max {
+		return max
+	}
+	return dt
+}
+
+// outboxAdd saves a message to the outbox, after a failed send (sendErr), or
+// if sendErr is nil, to send (with outboxSend) without an attempt yet.
+func (s *service) outboxAdd(ctx context.Context, msg *api.Message, sendErr error) (*outboxMessage, error) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	om := &outboxMessage{
+		ID:        msg.ID,
+		Channel:   msg.Channel,
+		Status:    MessagePending,
+		Message:   b,
+		Timestamp: msg.Timestamp,
+	}
+	if sendErr != nil {
+		om.Attempts = 1
+		om.Error = sendErr.Error()
+		om.NextAttempt = tsutil.Millis(s.clock.Now().Add(outboxBackoff(1)))
+	}
+	logger.Debugf("Save message %s to outbox", msg.ID)
+	if err := s.db.Set(ctx, dstore.Path("outbox", msg.ID), dstore.From(om)); err != nil {
+		return nil, err
+	}
+	s.relay.Send(relayMessageStatusEvent(om, false))
+	return om, nil
+}
+
+func (s *service) outboxMessage(ctx context.Context, id string) (*outboxMessage, error) {
+	doc, err := s.db.Get(ctx, dstore.Path("outbox", id))
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, nil
+	}
+	var om outboxMessage
+	if err := doc.To(&om); err != nil {
+		return nil, err
+	}
+	return &om, nil
+}
+
+// outboxMessages returns outbox messages for a channel, or all if channel is
+// empty, ordered by timestamp.
+func (s *service) outboxMessages(ctx context.Context, channel keys.ID) ([]*outboxMessage, error) {
+	docs, err := s.channelDocuments(ctx, "outbox", channel)
+	if err != nil {
+		return nil, err
+	}
+	out := []*outboxMessage{}
+	for _, doc := range docs {
+		var om outboxMessage
+		if err := doc.To(&om); err != nil {
+			return nil, err
+		}
+		out = append(out, &om)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Timestamp < out[j].Timestamp
+	})
+	return out, nil
+}
+
+// deleteOutboxMessages removes outbox messages for a channel (if we left).
+func (s *service) deleteOutboxMessages(ctx context.Context, channel keys.ID) error {
+	oms, err := s.outboxMessages(ctx, channel)
+	if err != nil {
+		return err
+	}
+	for _, om := range oms {
+		if _, err := s.db.Delete(ctx, dstore.Path("outbox", om.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outboxRetry tries to send pending outbox messages that are due.
+func (s *service) outboxRetry(ctx context.Context) error {
+	oms, err := s.outboxMessages(ctx, "")
+	if err != nil {
+		return err
+	}
+	now := s.clock.NowMillis()
+	for _, om := range oms {
+		if om.Status != MessagePending || om.NextAttempt > now {
+			continue
+		}
+		if err := s.outboxSend(ctx, om); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outboxSend tries to send an outbox message. If the send fails, the message
+// is scheduled for another attempt, or marked as failed after
+// outboxMaxAttempts. Returns error only if we failed to update the outbox.
+// If the message is already being sent, or was sent (or discarded) since om
+// was loaded, this does nothing.
+func (s *service) outboxSend(ctx context.Context, om *outboxMessage) error {
+	if !s.outboxSending.start(om.ID) {
+		logger.Debugf("Outbox message %s is already sending", om.ID)
+		return nil
+	}
+	defer s.outboxSending.end(om.ID)
+	exists, err := s.db.Exists(ctx, dstore.Path("outbox", om.ID))
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	msg, err := om.message()
+	if err != nil {
+		return err
+	}
+
+	// If the message made it to the server (and we missed the response), we
+	// don't want to send it again.
+	existing, err := s.findMessage(msg.Channel, msg.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		err = s.sendMessage(ctx, msg)
+	}
+	if existing != nil || err == nil {
+		logger.Debugf("Sent outbox message %s", om.ID)
+		if _, err := s.db.Delete(ctx, dstore.Path("outbox", om.ID)); err != nil {
+			return err
+		}
+		om.Status = MessageSent
+		s.relay.Send(relayMessageStatusEvent(om, false))
+		return nil
+	}
+
+	om.Attempts++
+	om.Error = err.Error()
+	if om.Attempts >= outboxMaxAttempts {
+		logger.Warningf("Failed to send outbox message %s (%d attempts): %v", om.ID, om.Attempts, err)
+		om.Status = MessageError
+		om.NextAttempt = 0
+	} else {
+		logger.Debugf("Failed to send outbox message %s (%d attempts): %v", om.ID, om.Attempts, err)
+		om.NextAttempt = tsutil.Millis(s.clock.Now().Add(outboxBackoff(om.Attempts)))
+	}
+	if err := s.db.Set(ctx, dstore.Path("outbox", om.ID), dstore.From(om)); err != nil {
+		return err
+	}
+	s.relay.Send(relayMessageStatusEvent(om, false))
+	if om.Status == MessageError {
+		if _, err := s.addSystemMessage(ctx, om.Channel, fmt.Sprintf("Message failed to send: %s", om.Error)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) sendMessage(ctx context.Context, msg *api.Message) error {
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	channelKey, err := s.keyring.Key(msg.Channel)
+	if err != nil {
+		return err
+	}
+	return s.client.SendMessage(ctx, msg, channelKey.AsEdX25519(), account.AsEdX25519())
+}
+
+// outboxMessagesToRPC returns outbox messages for the channel timeline, or
+// for a thread if parent is specified.
+func (s *service) outboxMessagesToRPC(ctx context.Context, channel keys.ID, parent string) ([]*Message, error) {
+	oms, err := s.outboxMessages(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Message, 0, len(oms))
+	for _, om := range oms {
+		msg, err := om.message()
+		if err != nil {
+			return nil, err
+		}
+		if msg.Parent != parent {
+			continue
+		}
+		m, err := s.messageToRPC(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		m.Status = om.Status
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// MessageResend (RPC) retries sending a pending or failed message.
+func (s *service) MessageResend(ctx context.Context, req *MessageResendRequest) (*MessageResendResponse, error) {
+	om, err := s.outboxMessageFromRPC(ctx, req.Channel, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	om.Status = MessagePending
+	om.Attempts = 0
+	if err := s.outboxSend(ctx, om); err != nil {
+		return nil, err
+	}
+
+	msg, err := om.message()
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.messageToRPC(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.outboxMessage(ctx, om.ID)
+	if err != nil {
+		return nil, err
+	}
+	if updated != nil {
+		out.Status = updated.Status
+	}
+	return &MessageResendResponse{Message: out}, nil
+}
+
+// MessageDiscard (RPC) removes a pending or failed message from the outbox.
+func (s *service) MessageDiscard(ctx context.Context, req *MessageDiscardRequest) (*MessageDiscardResponse, error) {
+	om, err := s.outboxMessageFromRPC(ctx, req.Channel, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !s.outboxSending.start(om.ID) {
+		return nil, errors.Errorf("message is sending")
+	}
+	defer s.outboxSending.end(om.ID)
+	if _, err := s.db.Delete(ctx, dstore.Path("outbox", om.ID)); err != nil {
+		return nil, err
+	}
+	s.relay.Send(relayMessageStatusEvent(om, true))
+	return &MessageDiscardResponse{}, nil
+}
+
+func (s *service) outboxMessageFromRPC(ctx context.Context, channel string, id string) (*outboxMessage, error) {
+	cid, err := keys.ParseID(channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if id == "" {
+		return nil, errors.Errorf("no message id specified")
+	}
+	om, err := s.outboxMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if om == nil || om.Channel != cid {
+		return nil, errors.Errorf("message not found in outbox")
+	}
+	return om, nil
+}
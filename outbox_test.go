@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	require.Equal(t, 2*time.Second, outboxBackoff(1))
+	require.Equal(t, 4*time.Second, outboxBackoff(2))
+	require.Equal(t, 16*time.Second, outboxBackoff(4))
+	require.Equal(t, 5*time.Minute, outboxBackoff(9))
+	require.Equal(t, 5*time.Minute, outboxBackoff(100))
+}
+
+func TestOutbox(t *testing.T) {
+	env := newTestServerEnv(t)
+	clock := env.clock.(*tsutil.TestClock)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	channel := channelCreate.ID
+	cid, err := keys.ParseID(channel)
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	// A message that failed to send is pending, and sent when retried.
+	msg := api.NewMessage(cid, alice.ID()).WithText("on a train").WithTimestamp(clock.NowMillis())
+	_, err = aliceService.outboxAdd(ctx, msg, errors.Errorf("offline"))
+	require.NoError(t, err)
+	messages, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel})
+	require.NoError(t, err)
+	last := messages.Messages[len(messages.Messages)-1]
+	require.Equal(t, msg.ID, last.ID)
+	require.Equal(t, MessagePending, last.Status)
+
+	// Not due yet
+	require.NoError(t, aliceService.outboxRetry(ctx))
+	om, err := aliceService.outboxMessage(ctx, msg.ID)
+	require.NoError(t, err)
+	require.NotNil(t, om)
+
+	clock.Add(time.Minute)
+	require.NoError(t, aliceService.outboxRetry(ctx))
+	om, err = aliceService.outboxMessage(ctx, msg.ID)
+	require.NoError(t, err)
+	require.Nil(t, om)
+	messages, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+	last = messages.Messages[len(messages.Messages)-1]
+	require.Equal(t, msg.ID, last.ID)
+	require.Equal(t, MessageSent, last.Status)
+
+	// Offline, the message fails after outboxMaxAttempts.
+	aliceServiceEnv.getChillAppEnv.closeFn()
+	send, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel, Text: "no signal"})
+	require.NoError(t, err)
+	require.Equal(t, MessagePending, send.Message.Status)
+	id := send.Message.ID
+	for i := 1; i < outboxMaxAttempts; i++ {
+		clock.Add(10 * time.Minute)
+		require.NoError(t, aliceService.outboxRetry(ctx))
+	}
+	om, err = aliceService.outboxMessage(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, MessageError, om.Status)
+	require.Equal(t, outboxMaxAttempts, om.Attempts)
+
+	// Failed messages aren't retried.
+	clock.Add(time.Hour)
+	require.NoError(t, aliceService.outboxRetry(ctx))
+	om, err = aliceService.outboxMessage(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, outboxMaxAttempts, om.Attempts)
+
+	messages, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel})
+	require.NoError(t, err)
+	n := len(messages.Messages)
+	require.True(t, messages.Messages[n-2].System)
+	require.Contains(t, messages.Messages[n-2].Text[0], "Message failed to send")
+	require.Equal(t, id, messages.Messages[n-1].ID)
+	require.Equal(t, MessageError, messages.Messages[n-1].Status)
+
+	// Resend (still offline) is pending again.
+	resend, err := aliceService.MessageResend(ctx, &MessageResendRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	require.Equal(t, MessagePending, resend.Message.Status)
+	om, err = aliceService.outboxMessage(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, om.Attempts)
+
+	// A message that is already sending isn't sent again, or discarded.
+	require.True(t, aliceService.outboxSending.start(id))
+	require.NoError(t, aliceService.outboxSend(ctx, om))
+	_, err = aliceService.MessageDiscard(ctx, &MessageDiscardRequest{Channel: channel, ID: id})
+	require.EqualError(t, err, "message is sending")
+	aliceService.outboxSending.end(id)
+	om, err = aliceService.outboxMessage(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 1, om.Attempts)
+
+	_, err = aliceService.MessageDiscard(ctx, &MessageDiscardRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	om, err = aliceService.outboxMessage(ctx, id)
+	require.NoError(t, err)
+	require.Nil(t, om)
+	_, err = aliceService.MessageDiscard(ctx, &MessageDiscardRequest{Channel: channel, ID: id})
+	require.EqualError(t, err, "message not found in outbox")
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/pkg/errors"
+)
+
+// MessagePin (RPC) pins a message in the channel, for everyone.
+func (s *service) MessagePin(ctx context.Context, req *MessagePinRequest) (*MessagePinResponse, error) {
+	if err := s.sendPin(ctx, req.Channel, req.ID, false); err != nil {
+		return nil, err
+	}
+	return &MessagePinResponse{}, nil
+}
+
+// MessageUnpin (RPC) unpins a message in the channel, for everyone.
+func (s *service) MessageUnpin(ctx context.Context, req *MessageUnpinRequest) (*MessageUnpinResponse, error) {
+	if err := s.sendPin(ctx, req.Channel, req.ID, true); err != nil {
+		return nil, err
+	}
+	return &MessageUnpinResponse{}, nil
+}
+
+// ChannelPins (RPC) lists pinned messages, most recently pinned first.
+func (s *service) ChannelPins(ctx context.Context, req *ChannelPinsRequest) (*ChannelPinsResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	agg, err := s.channelAggregate(channel)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.messagesToRPC(ctx, agg.unexpired(agg.pinnedMessages(), s.clock.NowMillis()), agg)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelPinsResponse{Messages: out}, nil
+}
+
+// sendPin sends a pin command, so all members see the same pins.
+func (s *service) sendPin(ctx context.Context, channel string, id string, remove bool) error {
+	cid, err := keys.ParseID(channel)
+	if err != nil {
+		return errors.Wrapf(err, "invalid channel")
+	}
+	if id == "" {
+		return errors.Errorf("no message id specified")
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	agg, err := s.channelAggregate(cid)
+	if err != nil {
+		return err
+	}
+	orig := agg.message(id)
+	if orig == nil || agg.expired(id, s.clock.NowMillis()) {
+		return errors.Errorf("message not found")
+	}
+	pinned := agg.pinned(id) != nil
+	switch {
+	case !remove && pinned:
+		return errors.Errorf("message already pinned")
+	case remove && !pinned:
+		return errors.Errorf("message not pinned")
+	case !remove && !visible(orig) && orig.Parent == "":
+		return errors.Errorf("can't pin a command")
+	}
+
+	msg := api.NewMessage(cid, account.ID).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{
+		MessagePin: &api.MessagePin{ID: orig.ID, Remove: remove},
+	}
+	return s.sendMessage(ctx, msg)
+}
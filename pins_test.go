@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinsAndSaved(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channelCreate, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{
+		Name: "testing",
+	})
+	require.NoError(t, err)
+	channel := channelCreate.ID
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	send, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel, Text: "runbook"})
+	require.NoError(t, err)
+	id := send.Message.ID
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+
+	_, err = aliceService.MessagePin(ctx, &MessagePinRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+	_, err = aliceService.MessagePin(ctx, &MessagePinRequest{Channel: channel, ID: id})
+	require.EqualError(t, err, "message already pinned")
+
+	pins, err := aliceService.ChannelPins(ctx, &ChannelPinsRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pins.Messages))
+	require.Equal(t, id, pins.Messages[0].ID)
+	require.True(t, pins.Messages[0].Pinned)
+	require.Equal(t, "alice", pins.Messages[0].PinnedBy)
+
+	_, err = aliceService.MessageUnpin(ctx, &MessageUnpinRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+	pins, err = aliceService.ChannelPins(ctx, &ChannelPinsRequest{Channel: channel})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(pins.Messages))
+
+	_, err = aliceService.MessageSave(ctx, &MessageSaveRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	saved, err := aliceService.SavedMessages(ctx, &SavedMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(saved.Saved))
+	require.Equal(t, channel, saved.Saved[0].Channel)
+	require.Equal(t, "testing", saved.Saved[0].ChannelName)
+	require.Equal(t, id, saved.Saved[0].Message.ID)
+	require.True(t, saved.Saved[0].Message.Saved)
+
+	_, err = aliceService.MessageUnsave(ctx, &MessageUnsaveRequest{ID: id})
+	require.NoError(t, err)
+	saved, err = aliceService.SavedMessages(ctx, &SavedMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(saved.Saved))
+	_, err = aliceService.MessageUnsave(ctx, &MessageUnsaveRequest{ID: id})
+	require.EqualError(t, err, "saved message not found")
+
+	// Deleted messages are skipped
+	_, err = aliceService.MessageSave(ctx, &MessageSaveRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	_, err = aliceService.MessageDelete(ctx, &MessageDeleteRequest{Channel: channel, ID: id})
+	require.NoError(t, err)
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+	require.NoError(t, err)
+	saved, err = aliceService.SavedMessages(ctx, &SavedMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(saved.Saved))
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	kapi "github.com/keys-pub/keys/api"
+	"github.com/pkg/errors"
+)
+
+// MessageReact (RPC) adds a reaction to a message.
+func (s *service) MessageReact(ctx context.Context, req *MessageReactRequest) (*MessageReactResponse, error) {
+	if err := s.sendReaction(ctx, req.Channel, req.ID, req.Emoji, false); err != nil {
+		return nil, err
+	}
+	return &MessageReactResponse{}, nil
+}
+
+// MessageUnreact (RPC) removes our reaction from a message.
+func (s *service) MessageUnreact(ctx context.Context, req *MessageUnreactRequest) (*MessageUnreactResponse, error) {
+	if err := s.sendReaction(ctx, req.Channel, req.ID, req.Emoji, true); err != nil {
+		return nil, err
+	}
+	return &MessageUnreactResponse{}, nil
+}
+
+func (s *service) sendReaction(ctx context.Context, channel string, id string, emoji string, remove bool) error {
+	cid, err := keys.ParseID(channel)
+	if err != nil {
+		return errors.Wrapf(err, "invalid channel")
+	}
+	if id == "" {
+		return errors.Errorf("no message id specified")
+	}
+	if emoji == "" {
+		return errors.Errorf("no emoji specified")
+	}
+	if utf8.RuneCountInString(emoji) > 16 {
+		return errors.Errorf("invalid emoji")
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	orig, err := s.findMessage(cid, id)
+	if err != nil {
+		return err
+	}
+	if orig == nil {
+		return errors.Errorf("message not found")
+	}
+
+	msg := api.NewMessage(cid, account.ID).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{
+		MessageReaction: &api.MessageReaction{ID: orig.ID, Emoji: emoji, Remove: remove},
+	}
+	return s.sendMessage(ctx, msg)
+}
+
+func (s *service) reactionsToRPC(ctx context.Context, reactions []*reaction, account *kapi.Key) ([]*MessageReaction, error) {
+	out := make([]*MessageReaction, 0, len(reactions))
+	for _, r := range reactions {
+		users := make([]string, 0, len(r.senders))
+		me := false
+		for _, sender := range r.senders {
+			if account != nil && sender == account.ID {
+				me = true
+			}
+			// Uses the cached username, so users who were removed still show.
+			name, err := s.userName(ctx, sender)
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = sender.String()
+			}
+			users = append(users, name)
+		}
+		out = append(out, &MessageReaction{
+			Emoji: r.emoji,
+			Count: int32(len(r.senders)),
+			Me:    me,
+			Users: users,
+		})
+	}
+	return out, nil
+}
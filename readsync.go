@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+)
+
+// Read markers sync to our other devices through a users channel that only we
+// are in. Its key is derived from the account key, so all our devices use the
+// same channel without having to find it. Each message in it is a read marker.
+// It isn't added to the messenger, so it isn't listed with the other channels.
+
+// readSync is where we are in the read sync channel, at /readsync/state.
+type readSync struct {
+	// Created if the channel is on the server.
+	Created bool `json:"created,omitempty"`
+	// Index is the remote index of the last marker we pulled.
+	Index int64 `json:"index"`
+}
+
+// readMarker is the text of a message in the read sync channel.
+type readMarker struct {
+	Channel keys.ID `json:"channel"`
+	Index   int64   `json:"index"`
+}
+
+// readSyncKey returns the key for the read sync channel.
+func readSyncKey(account *keys.EdX25519Key) *keys.EdX25519Key {
+	seed := keys.Bytes32(keys.HKDFSHA256(account.Seed()[:], 32, nil, []byte("getchill.app/read-sync")))
+	return keys.NewEdX25519KeyFromSeed(seed)
+}
+
+func (s *service) readSync(ctx context.Context) (*readSync, error) {
+	var rs readSync
+	if _, err := s.db.Load(ctx, dstore.Path("readsync", "state"), &rs); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// markRead moves the read marker for a channel. Returns false if it didn't
+// move (the marker only moves forward).
+func (s *service) markRead(ctx context.Context, cid keys.ID, index int64) (bool, error) {
+	unlock := s.unreadLocks.lock(cid)
+	defer unlock()
+	unread, err := s.loadUnread(ctx, cid)
+	if err != nil {
+		return false, err
+	}
+	if !unread.read(index) {
+		return false, nil
+	}
+	logger.Debugf("Channel read %s (%d)", cid, index)
+	if err := s.db.Set(ctx, dstore.Path("unread", cid), dstore.From(unread)); err != nil {
+		return false, err
+	}
+	s.relay.Send(relayReadEvent(cid, index))
+	return true, nil
+}
+
+// sendReadMarker sends a read marker to our other devices. The read sync
+// channel is created the first time.
+func (s *service) sendReadMarker(ctx context.Context, cid keys.ID, index int64) error {
+	s.readSyncMtx.Lock()
+	defer s.readSyncMtx.Unlock()
+
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	key := readSyncKey(account.AsEdX25519())
+	rs, err := s.readSync(ctx)
+	if err != nil {
+		return err
+	}
+	if !rs.Created {
+		// If another device created it, we find out on the next channels
+		// update (see pullReadMarkers).
+		logger.Debugf("Creating read sync channel %s", key.ID())
+		if _, err := s.client.ChannelCreateWithUsers(ctx, key, &api.ChannelInfo{}, []keys.ID{account.ID}, account.AsEdX25519()); err != nil {
+			return err
+		}
+		rs.Created = true
+		if err := s.db.Set(ctx, dstore.Path("readsync", "state"), dstore.From(rs)); err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(&readMarker{Channel: cid, Index: index})
+	if err != nil {
+		return err
+	}
+	msg := api.NewMessage(key.ID(), account.ID).WithText(string(b)).WithTimestamp(s.clock.NowMillis())
+	return s.client.SendMessage(ctx, msg, key, account.AsEdX25519())
+}
+
+// pullReadMarkers applies read markers from our other devices (and our own),
+// if the read sync channel has new messages. Markers for channels we don't
+// have are skipped. Called when we update channels, with the read sync
+// channel from the server.
+func (s *service) pullReadMarkers(ctx context.Context, channel *api.Channel) error {
+	s.readSyncMtx.Lock()
+	defer s.readSyncMtx.Unlock()
+
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	key := readSyncKey(account.AsEdX25519())
+	rs, err := s.readSync(ctx)
+	if err != nil {
+		return err
+	}
+	if rs.Created && rs.Index == channel.Index {
+		return nil
+	}
+	rs.Created = true
+
+	markers := map[keys.ID]int64{}
+	index := rs.Index
+	for {
+		logger.Debugf("Pulling read markers idx=%d", index)
+		msgs, err := s.client.Messages(ctx, key, index)
+		if err != nil {
+			return err
+		}
+		if msgs == nil {
+			break
+		}
+		for _, msg := range msgs.Messages {
+			if msg.RemoteIndex > rs.Index {
+				rs.Index = msg.RemoteIndex
+			}
+			var marker readMarker
+			if err := json.Unmarshal([]byte(msg.Text), &marker); err != nil {
+				logger.Warningf("Invalid read marker %s: %v", msg.ID, err)
+				continue
+			}
+			if marker.Index > markers[marker.Channel] {
+				markers[marker.Channel] = marker.Index
+			}
+		}
+		if !msgs.Truncated {
+			break
+		}
+		index = msgs.Index
+	}
+
+	for cid, index := range markers {
+		existing, err := s.messenger.Channel(cid)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			continue
+		}
+		if _, err := s.markRead(ctx, cid, index); err != nil {
+			return err
+		}
+	}
+	return s.db.Set(ctx, dstore.Path("readsync", "state"), dstore.From(rs))
+}
@@ -2,67 +2,276 @@ package service
 
 import (
 	"context"
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/getchill-app/http/api"
 	wsapi "github.com/getchill-app/ws/api"
 	wsclient "github.com/getchill-app/ws/client"
+	"github.com/keys-pub/keys"
 	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/dstore/events"
 	"github.com/pkg/errors"
 )
 
-type relayClient struct {
-	ui RPC_RelayServer
-	ws *wsclient.Client
+// relayBufferSize is the number of events buffered for a subscriber. If a
+// subscriber falls this far behind it is disconnected, and can resubscribe
+// (and refresh) when it's ready.
+const relayBufferSize = 256
+
+// relayBackoff returns how long to wait before reconnect attempt n (from 1),
+// exponential up to a minute, with jitter so clients don't all reconnect at
+// the same time.
+func relayBackoff(attempt int) time.Duration {
+	max := time.Minute
+	dt := max
+	if attempt < 7 {
+		dt = time.Duration(1<<uint(attempt-1)) * time.Second
+	}
+	// Between dt/2 and dt
+	return dt/2 + time.Duration(rand.Int63n(int64(dt/2)+1))
+}
+
+// relaySubscriber receives relay events, for example for a UI connected to
+// the Relay RPC.
+type relaySubscriber struct {
+	ch   chan *RelayOutput
+	done chan struct{}
+	err  error
 }
 
+// relay fans out events to subscribers and owns the (shared) websocket
+// connection to the chill relay, which is open while there are subscribers.
+// If the connection fails, we reconnect (with backoff) and subscribers get
+// connection state events.
 type relay struct {
 	sync.Mutex
-	client *relayClient
+	subs map[*relaySubscriber]struct{}
+	ws   *wsclient.Client
+	// state is the last connection event.
+	state *RelayOutput
+
+	// connect runs the websocket connection until the context is canceled or
+	// there is an error.
+	connect func(ctx context.Context) error
+	backoff func(attempt int) time.Duration
+	refs    int
+	cancel  context.CancelFunc
+	// done is closed when run returns.
+	done chan struct{}
+	// suspended is set while locked, so we don't connect even if there are
+	// subscribers.
+	suspended bool
+	// attempt is the number of failed connects since we were last connected.
+	attempt int
 }
 
 func newRelay() *relay {
-	return &relay{}
+	return &relay{
+		subs:    map[*relaySubscriber]struct{}{},
+		backoff: relayBackoff,
+	}
+}
+
+// Subscribe adds a subscriber, opening the relay connection if needed.
+func (r *relay) Subscribe() *relaySubscriber {
+	sub := &relaySubscriber{
+		ch:   make(chan *RelayOutput, relayBufferSize),
+		done: make(chan struct{}),
+	}
+	r.Lock()
+	r.subs[sub] = struct{}{}
+	if r.state != nil {
+		// Let this subscriber know the current state, if connected it
+		// should refresh.
+		sub.ch <- r.state
+	}
+	r.Unlock()
+	r.acquire()
+	return sub
 }
 
-func (r *relay) Register(client *relayClient) {
+// Unsubscribe removes a subscriber, closing the relay connection if it was
+// the last one.
+func (r *relay) Unsubscribe(sub *relaySubscriber) {
+	r.Lock()
+	if _, ok := r.subs[sub]; ok {
+		r.closeSubscriber(sub, nil)
+	}
+	r.Unlock()
+	r.release()
+}
+
+// closeSubscriber removes the subscriber. Caller should hold the lock.
+func (r *relay) closeSubscriber(sub *relaySubscriber, err error) {
+	delete(r.subs, sub)
+	sub.err = err
+	close(sub.done)
+}
+
+func (r *relay) acquire() {
 	r.Lock()
 	defer r.Unlock()
-	r.client = client
+	r.refs++
+	r.open()
 }
 
-func (r *relay) Unregister(client *relayClient) {
+// open starts run if there are refs and it isn't running. Caller should hold
+// the lock.
+func (r *relay) open() {
+	if r.refs == 0 || r.cancel != nil || r.connect == nil || r.suspended {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.run(ctx, r.done)
+}
+
+// run connects, and reconnects on failure, until the context is canceled.
+func (r *relay) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for {
+		err := r.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warningf("Relay disconnected: %v", err)
+		r.Send(relayConnectionEvent(RelayDisconnected))
+
+		r.Lock()
+		r.attempt++
+		wait := r.backoff(r.attempt)
+		r.Unlock()
+
+		logger.Infof("Relay reconnecting in %s", wait)
+		r.Send(relayConnectionEvent(RelayReconnecting))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// release removes a ref, closing the connection if it was the last one. If
+// closing, waits for run to return.
+func (r *relay) release() {
+	r.Lock()
+	r.refs--
+	if r.refs > 0 {
+		r.Unlock()
+		return
+	}
+	r.close()
+}
+
+// suspend closes the connection, even if there are refs, and waits for run to
+// return, so we can lock (and close the db) with UI subscribers still
+// connected. They stay subscribed and we reconnect on resume.
+func (r *relay) suspend() {
+	r.Lock()
+	r.suspended = true
+	r.close()
+}
+
+// resume reconnects after suspend, if there are refs.
+func (r *relay) resume() {
 	r.Lock()
 	defer r.Unlock()
-	if client == r.client {
-		r.client = nil
+	r.suspended = false
+	r.open()
+}
+
+// close cancels run and waits for it to return. Caller should hold the lock,
+// which is released, since run needs it (to Send) before it returns.
+func (r *relay) close() {
+	cancel, done := r.cancel, r.done
+	r.cancel = nil
+	r.done = nil
+	r.state = nil
+	r.attempt = 0
+	r.Unlock()
+	if cancel == nil {
+		return
 	}
+	cancel()
+	<-done
 }
 
+// Send an event to all subscribers.
+// Subscribers that can't keep up are disconnected.
 func (r *relay) Send(out *RelayOutput) {
 	r.Lock()
 	defer r.Unlock()
-	if r.client != nil {
-		if err := r.client.ui.Send(out); err != nil {
-			logger.Errorf("Failed to relay event: %v", err)
+	if conn := out.GetConnection(); conn != nil {
+		r.state = out
+		if conn.State == RelayConnected {
+			r.attempt = 0
+		}
+	}
+	for sub := range r.subs {
+		select {
+		case sub.ch <- out:
+		default:
+			logger.Warningf("Relay subscriber is too slow, disconnecting")
+			r.closeSubscriber(sub, errors.Errorf("relay subscriber too slow"))
 		}
 	}
 }
 
+func (r *relay) setWS(ws *wsclient.Client) {
+	r.Lock()
+	defer r.Unlock()
+	r.ws = ws
+}
+
 func (r *relay) RegisterTokens(tokens []string) {
 	r.Lock()
 	defer r.Unlock()
-	if r.client != nil {
-		if err := r.client.ws.Register(tokens); err != nil {
+	if r.ws != nil {
+		if err := r.ws.Register(tokens); err != nil {
 			logger.Errorf("Failed to relay auth: %v", err)
 		}
 	}
 }
 
-// Relay (RPC) ...
+// Relay (RPC) streams relay events. Multiple clients can subscribe at the
+// same time and share the relay connection.
 func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 	ctx := srv.Context()
 
+	if _, err := s.account(true); err != nil {
+		return err
+	}
+
+	sub := s.relay.Subscribe()
+	defer s.relay.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-sub.done:
+			if sub.err != nil {
+				return sub.err
+			}
+			return nil
+		case out := <-sub.ch:
+			if err := srv.Send(out); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// relayConnect connects to the chill relay and handles events, until the
+// context is canceled or there is an error. On (re)connect, we re-register
+// channel tokens and update channels, which pulls messages for channels whose
+// index moved while we were disconnected.
+func (s *service) relayConnect(ctx context.Context) error {
 	account, err := s.account(true)
 	if err != nil {
 		return err
@@ -86,13 +295,13 @@ func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 	}
 	defer relay.Close()
 
-	client := &relayClient{ui: srv, ws: relay}
-	s.relay.Register(client)
-	defer s.relay.Unregister(client)
+	s.relay.setWS(relay)
+	defer s.relay.setWS(nil)
 
 	chEvents := make(chan []*wsapi.Event)
 
 	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	go func() {
 		for {
@@ -103,7 +312,11 @@ func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 				cancel()
 				return
 			}
-			chEvents <- events
+			select {
+			case chEvents <- events:
+			case <-wctx.Done():
+				return
+			}
 		}
 	}()
 
@@ -121,14 +334,20 @@ func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 	}
 
 	// Send relay event after connect/register/update
-	if err := srv.Send(&RelayOutput{Type: "connected"}); err != nil {
-		return err
+	s.relay.Send(relayConnectionEvent(RelayConnected))
+
+	// We're connected, try to send anything in the outbox.
+	if err := s.outboxRetry(ctx); err != nil {
+		logger.Warningf("Failed to retry outbox: %v", err)
 	}
 
 	ticker := time.NewTicker(50 * time.Second)
+	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			return ctx.Err()
 		case <-wctx.Done():
 			return errors.Wrapf(wctx.Err(), "relay failed")
 		case <-ticker.C:
@@ -136,6 +355,9 @@ func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 			if err := relay.Ping(); err != nil {
 				return err
 			}
+			if err := s.outboxRetry(ctx); err != nil {
+				logger.Warningf("Failed to retry outbox: %v", err)
+			}
 		case events := <-chEvents:
 			logger.Infof("Got relay events...")
 			for _, event := range events {
@@ -147,39 +369,137 @@ func (s *service) Relay(req *RelayRequest, srv RPC_RelayServer) error {
 					case wsapi.ChannelType:
 						if event.Channel != nil && event.Channel.ID != "" {
 							logger.Debugf("Channel event %s", event.Channel.ID)
-							if err := s.PullMessages(ctx, event.Channel.ID); err != nil {
+							left, err := s.channelLeft(ctx, event.Channel.ID)
+							if err != nil {
 								return err
 							}
+							if left {
+								continue
+							}
+							out, err := s.pullChannelEvent(ctx, event.Channel.ID)
+							if err != nil {
+								return err
+							}
+							s.relay.Send(out)
 						}
 					case wsapi.ChannelsType:
 						logger.Debugf("Channels event")
 						if err := s.updateChannels(ctx); err != nil {
 							return err
 						}
+						s.relay.Send(relayChannelsEvent())
 					}
 				}
 			}
-			for _, event := range events {
-				out := relayEventToRPC(event)
-				if err := srv.Send(out); err != nil {
-					return err
-				}
-			}
 		}
 	}
 }
 
-func relayEventToRPC(event *wsapi.Event) *RelayOutput {
-	out := &RelayOutput{
-		Type: string(event.Type),
+// pullChannelEvent pulls messages and returns a channel event with messages
+// that are new or were changed.
+func (s *service) pullChannelEvent(ctx context.Context, cid keys.ID) (*RelayOutput, error) {
+	channel, err := s.messenger.Channel(cid)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, errors.Errorf("channel not found")
+	}
+	since := channel.MessageIndex
+	if err := s.PullMessages(ctx, cid); err != nil {
+		return nil, err
 	}
-	if event.Channel != nil {
-		out.Channel = &RelayOutput_Channel{
-			ID:    event.Channel.ID.String(),
-			Index: event.Channel.Index,
+
+	msgs, err := s.messenger.MessagesFrom(cid, since, events.Ascending, 0)
+	if err != nil {
+		return nil, err
+	}
+	agg, err := s.channelAggregate(cid)
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]bool{}
+	index := since
+	for _, msg := range msgs {
+		index = msg.RemoteIndex
+		if msg.Parent != "" {
+			changed[msg.Parent] = true
+		}
+		if cmd := msg.Command; cmd != nil {
+			switch {
+			case cmd.MessageEdit != nil:
+				changed[cmd.MessageEdit.ID] = true
+			case cmd.MessageDelete != nil:
+				changed[cmd.MessageDelete.ID] = true
+			case cmd.MessageReaction != nil:
+				changed[cmd.MessageReaction.ID] = true
+			case cmd.MessagePin != nil:
+				changed[cmd.MessagePin.ID] = true
+			}
+		}
+		if msg.Parent != "" || visible(msg) {
+			changed[msg.ID] = true
 		}
 	}
-	return out
+	updated := []*api.Message{}
+	for id := range changed {
+		if msg := agg.message(id); msg != nil {
+			updated = append(updated, msg)
+		}
+	}
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].RemoteIndex < updated[j].RemoteIndex
+	})
+	out, err := s.messagesToRPC(ctx, updated, agg)
+	if err != nil {
+		return nil, err
+	}
+	return &RelayOutput{Event: &RelayOutput_Channel{Channel: &RelayChannel{
+		ID:       cid.String(),
+		Index:    index,
+		Messages: out,
+	}}}, nil
+}
+
+func relayConnectionEvent(state RelayState) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Connection{Connection: &RelayConnection{State: state}}}
+}
+
+func relayChannelsEvent() *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Channels{Channels: &RelayChannels{}}}
+}
+
+func relayMessageStatusEvent(om *outboxMessage, discarded bool) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_MessageStatus{MessageStatus: &RelayMessageStatus{
+		Channel:   om.Channel.String(),
+		ID:        om.ID,
+		Status:    om.Status,
+		Discarded: discarded,
+	}}}
+}
+
+func relayReadEvent(channel keys.ID, index int64) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Read{Read: &RelayRead{Channel: channel.String(), Index: index}}}
+}
+
+func relaySyncEvent(channel keys.ID, completed int, total int, err error) *RelayOutput {
+	progress := &RelaySync{
+		Channel:   channel.String(),
+		Completed: int32(completed),
+		Total:     int32(total),
+	}
+	if err != nil {
+		progress.Error = err.Error()
+	}
+	return &RelayOutput{Event: &RelayOutput_Sync{Sync: progress}}
+}
+
+func relayDraftEvent(channel keys.ID, d *draft) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Draft{Draft: &RelayDraft{Channel: channel.String(), Draft: d.toRPC()}}}
+}
+
+func relayLockEvent(locked bool) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Lock{Lock: &RelayLock{Locked: locked}}}
 }
 
 func (s *service) relayTokens(ctx context.Context) ([]string, error) {
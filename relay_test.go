@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelaySubscribers(t *testing.T) {
+	r := newRelay()
+	started := make(chan struct{}, 10)
+	stopped := make(chan struct{}, 10)
+	r.connect = func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- struct{}{}
+		return ctx.Err()
+	}
+
+	sub1 := r.Subscribe()
+	sub2 := r.Subscribe()
+	<-started
+
+	r.Send(relayConnectionEvent(RelayConnected))
+	require.Equal(t, RelayConnected, (<-sub1.ch).GetConnection().State)
+	require.Equal(t, RelayConnected, (<-sub2.ch).GetConnection().State)
+
+	// New subscriber is told we're connected
+	sub3 := r.Subscribe()
+	require.Equal(t, RelayConnected, (<-sub3.ch).GetConnection().State)
+	r.Unsubscribe(sub3)
+
+	r.Unsubscribe(sub1)
+	r.Send(relayChannelsEvent())
+	require.NotNil(t, (<-sub2.ch).GetChannels())
+	select {
+	case <-sub1.done:
+	default:
+		t.Fatal("expected sub1 done")
+	}
+
+	// Connection is shared, closed after the last subscriber leaves
+	select {
+	case <-started:
+		t.Fatal("connect called twice")
+	default:
+	}
+	r.Unsubscribe(sub2)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("connection not closed")
+	}
+}
+
+func TestRelaySlowSubscriber(t *testing.T) {
+	r := newRelay()
+	sub := r.Subscribe()
+	for i := 0; i < relayBufferSize+1; i++ {
+		r.Send(relayChannelsEvent())
+	}
+	<-sub.done
+	require.EqualError(t, sub.err, "relay subscriber too slow")
+	r.Unsubscribe(sub)
+}
+
+func TestRelayReconnect(t *testing.T) {
+	r := newRelay()
+	r.backoff = func(attempt int) time.Duration { return time.Millisecond }
+	attempts := 0
+	r.connect = func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return errors.Errorf("connect failed")
+		}
+		r.Send(relayConnectionEvent(RelayConnected))
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	sub := r.Subscribe()
+	states := []RelayState{}
+	for len(states) < 5 {
+		states = append(states, (<-sub.ch).GetConnection().State)
+	}
+	require.Equal(t, []RelayState{RelayDisconnected, RelayReconnecting, RelayDisconnected, RelayReconnecting, RelayConnected}, states)
+	r.Lock()
+	require.Equal(t, 0, r.attempt)
+	r.Unlock()
+	r.Unsubscribe(sub)
+}
+
+func TestRelayBackoff(t *testing.T) {
+	for i := 1; i < 12; i++ {
+		dt := relayBackoff(i)
+		require.True(t, dt > 0)
+		require.True(t, dt <= time.Minute)
+	}
+	require.True(t, relayBackoff(1) <= time.Second)
+	require.True(t, relayBackoff(20) >= 30*time.Second)
+}
+
+func TestRelayReleaseWaits(t *testing.T) {
+	r := newRelay()
+	started := make(chan struct{}, 10)
+	var stopped bool
+	r.connect = func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		stopped = true
+		return ctx.Err()
+	}
+
+	r.acquire()
+	<-started
+	r.release()
+	require.True(t, stopped)
+}
+
+func TestRelaySuspend(t *testing.T) {
+	r := newRelay()
+	started := make(chan struct{}, 10)
+	var stopped bool
+	r.connect = func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		stopped = true
+		return ctx.Err()
+	}
+
+	sub := r.Subscribe()
+	<-started
+	r.suspend()
+	require.True(t, stopped)
+
+	// Subscribing while suspended doesn't connect
+	sub2 := r.Subscribe()
+	select {
+	case <-started:
+		t.Fatal("connected while suspended")
+	default:
+	}
+
+	// Resume reconnects for subscribers
+	r.resume()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("not reconnected")
+	}
+	r.Unsubscribe(sub)
+	r.Unsubscribe(sub2)
+}
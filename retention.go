@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/pkg/errors"
+)
+
+// Messages disappear after the channel retention, which is set with a channel
+// retention command, so all members agree. Expired messages are hidden, and
+// removed (from the messenger and search index) by the reaper.
+
+const reapInterval = time.Minute
+
+const minRetention = time.Minute
+const maxRetention = 365 * 24 * time.Hour
+
+// expires returns false for messages that are kept regardless of retention,
+// since we need them for the channel info (and retention).
+func expires(msg *api.Message) bool {
+	if msg.Command == nil {
+		return true
+	}
+	return msg.Command.ChannelInfo == nil && msg.Command.ChannelRetention == nil
+}
+
+// messageTime is when the message was received by the server, or when it was
+// sent if we don't know.
+func messageTime(msg *api.Message) int64 {
+	if msg.RemoteTimestamp != 0 {
+		return msg.RemoteTimestamp
+	}
+	return msg.Timestamp
+}
+
+func validateRetention(retention int64) error {
+	if retention == 0 {
+		return nil
+	}
+	d := time.Duration(retention) * time.Millisecond
+	if d < minRetention {
+		return errors.Errorf("retention too short (must be at least %s)", retentionString(int64(minRetention/time.Millisecond)))
+	}
+	if d > maxRetention {
+		return errors.Errorf("retention too long (must be at most %s)", retentionString(int64(maxRetention/time.Millisecond)))
+	}
+	return nil
+}
+
+// retentionString returns a retention (in milliseconds) for display, for
+// example "1 day" or "30 minutes".
+func retentionString(retention int64) string {
+	d := time.Duration(retention) * time.Millisecond
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	day := 24 * time.Hour
+	switch {
+	case d%day == 0:
+		return plural(int64(d/day), "day")
+	case d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d%time.Minute == 0:
+		return plural(int64(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
+
+// parseRetention parses a retention like "30m", "12h", "7d" or "off".
+func parseRetention(s string) (int64, error) {
+	if s == "off" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("invalid retention %s", s)
+		}
+		return int64(time.Duration(n) * 24 * time.Hour / time.Millisecond), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.Errorf("invalid retention %s", s)
+	}
+	return int64(d / time.Millisecond), nil
+}
+
+// expiredMessages returns IDs of messages that expired at now, and commands
+// (edits, deletes, reactions, pins) for them.
+func expiredMessages(msgs []*api.Message, agg *messageAggregate, now int64) []string {
+	ids := []string{}
+	for _, msg := range msgs {
+		if agg.expired(msg.ID, now) {
+			ids = append(ids, msg.ID)
+			continue
+		}
+		if msg.Command == nil {
+			continue
+		}
+		var target string
+		switch {
+		case msg.Command.MessageEdit != nil:
+			target = msg.Command.MessageEdit.ID
+		case msg.Command.MessageDelete != nil:
+			target = msg.Command.MessageDelete.ID
+		case msg.Command.MessageReaction != nil:
+			target = msg.Command.MessageReaction.ID
+		case msg.Command.MessagePin != nil:
+			target = msg.Command.MessagePin.ID
+		}
+		if target != "" && agg.expired(target, now) {
+			ids = append(ids, msg.ID)
+		}
+	}
+	return ids
+}
+
+// reapExpired removes expired messages from channels that have (or had) a
+// retention.
+func (s *service) reapExpired(ctx context.Context) error {
+	channels, err := s.messenger.Channels()
+	if err != nil {
+		return err
+	}
+	now := s.clock.NowMillis()
+	for _, channel := range channels {
+		info, err := s.channelInfo(ctx, channel.ID)
+		if err != nil {
+			return err
+		}
+		if !info.Expiring {
+			continue
+		}
+		msgs, err := s.messenger.Messages(channel.ID)
+		if err != nil {
+			return err
+		}
+		sort.SliceStable(msgs, func(i, j int) bool {
+			return msgs[i].RemoteIndex < msgs[j].RemoteIndex
+		})
+		ids := expiredMessages(msgs, aggregateMessages(msgs), now)
+		if len(ids) == 0 {
+			continue
+		}
+		logger.Debugf("Removing %d expired message(s) from %s", len(ids), channel.ID)
+		if err := s.messenger.DeleteMessages(channel.ID, ids); err != nil {
+			return err
+		}
+		s.aggregates.remove(channel.ID)
+		if err := s.removeUnread(ctx, channel.ID, msgs, ids); err != nil {
+			return err
+		}
+		if err := s.unindexMessages(ctx, ids); err != nil {
+			return err
+		}
+		s.relay.Send(relayExpiredEvent(channel.ID, ids))
+	}
+	return nil
+}
+
+func relayExpiredEvent(channel keys.ID, ids []string) *RelayOutput {
+	return &RelayOutput{Event: &RelayOutput_Channel{Channel: &RelayChannel{ID: channel.String(), Expired: ids}}}
+}
+
+// setExpiresIn sets the remaining lifetime.
+func setExpiresIn(m *Message, now int64) {
+	if m.ExpiresAt == 0 {
+		return
+	}
+	m.ExpiresIn = m.ExpiresAt - now
+	if m.ExpiresIn < 0 {
+		m.ExpiresIn = 0
+	}
+}
+
+// ChannelRetentionSet (RPC) sends a channel retention command, so messages
+// (sent after) disappear.
+func (s *service) ChannelRetentionSet(ctx context.Context, req *ChannelRetentionSetRequest) (*ChannelRetentionSetResponse, error) {
+	cid, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if err := validateRetention(req.Retention); err != nil {
+		return nil, err
+	}
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := api.NewMessage(cid, account.ID).WithTimestamp(s.clock.NowMillis())
+	msg.Command = &api.MessageCommand{ChannelRetention: &api.ChannelRetention{TTL: req.Retention}}
+	if err := s.sendMessage(ctx, msg); err != nil {
+		return nil, err
+	}
+	out, err := s.messageToRPC(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &ChannelRetentionSetResponse{Message: out}, nil
+}
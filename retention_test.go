@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateExpires(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	msgs := []*api.Message{
+		{ID: "m1", Sender: "alice", Text: "kept", RemoteIndex: 1, RemoteTimestamp: 1000},
+		{ID: "m2", Sender: "alice", RemoteIndex: 2, RemoteTimestamp: 2000, Command: &api.MessageCommand{
+			ChannelRetention: &api.ChannelRetention{TTL: hour},
+		}},
+		{ID: "m3", Sender: "bob", Text: "secret", RemoteIndex: 3, RemoteTimestamp: 3000},
+		{ID: "m4", Sender: "bob", RemoteIndex: 4, RemoteTimestamp: 4000, Command: &api.MessageCommand{
+			MessageEdit: &api.MessageEdit{ID: "m3", Text: "secret!"},
+		}},
+		{ID: "m5", Sender: "alice", RemoteIndex: 5, RemoteTimestamp: 5000, Command: &api.MessageCommand{
+			ChannelInfo: &api.ChannelInfo{Topic: "secrets"},
+		}},
+		{ID: "m6", Sender: "alice", RemoteIndex: 6, RemoteTimestamp: 6000, Command: &api.MessageCommand{
+			ChannelRetention: &api.ChannelRetention{TTL: 0},
+		}},
+		{ID: "m7", Sender: "bob", Text: "kept", RemoteIndex: 7, RemoteTimestamp: 7000},
+	}
+	agg := aggregateMessages(msgs)
+	require.Equal(t, map[string]int64{"m3": 3000 + hour, "m4": 4000 + hour}, agg.expires)
+
+	m3 := &Message{ID: "m3"}
+	agg.apply(m3, 3000)
+	require.Equal(t, 3000+hour, m3.ExpiresAt)
+	setExpiresIn(m3, 3000)
+	require.Equal(t, hour, m3.ExpiresIn)
+	setExpiresIn(m3, 3000+2*hour)
+	require.Equal(t, int64(0), m3.ExpiresIn)
+
+	now := 3500 + hour
+	visible := agg.unexpired(visibleMessages(msgs), now)
+	ids := []string{}
+	for _, msg := range visible {
+		ids = append(ids, msg.ID)
+	}
+	require.Equal(t, []string{"m1", "m2", "m5", "m6", "m7"}, ids)
+
+	// The edit goes with the message
+	require.Equal(t, []string{"m3", "m4"}, expiredMessages(msgs, agg, now))
+	require.Equal(t, []string{}, expiredMessages(msgs, agg, 2000+hour))
+}
+
+func TestParseRetention(t *testing.T) {
+	r, err := parseRetention("7d")
+	require.NoError(t, err)
+	require.Equal(t, "7 days", retentionString(r))
+	r, err = parseRetention("1h")
+	require.NoError(t, err)
+	require.Equal(t, "1 hour", retentionString(r))
+	r, err = parseRetention("90m")
+	require.NoError(t, err)
+	require.Equal(t, "90 minutes", retentionString(r))
+	r, err = parseRetention("off")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), r)
+	_, err = parseRetention("forever")
+	require.EqualError(t, err, "invalid retention forever")
+
+	require.NoError(t, validateRetention(0))
+	require.NoError(t, validateRetention(int64(time.Hour/time.Millisecond)))
+	require.EqualError(t, validateRetention(1000), "retention too short (must be at least 1 minute)")
+	require.EqualError(t, validateRetention(int64(400*24*time.Hour/time.Millisecond)), "retention too long (must be at most 365 days)")
+}
+
+func TestChannelInfoRetention(t *testing.T) {
+	info := &channelInfo{}
+	info.apply([]*api.Message{
+		{RemoteIndex: 1, Command: &api.MessageCommand{ChannelRetention: &api.ChannelRetention{TTL: 60000}}},
+	})
+	require.Equal(t, int64(60000), info.Retention)
+	require.True(t, info.Expiring)
+	info.apply([]*api.Message{
+		{RemoteIndex: 2, Command: &api.MessageCommand{ChannelRetention: &api.ChannelRetention{}}},
+	})
+	require.Equal(t, int64(0), info.Retention)
+	// Older messages can still expire.
+	require.True(t, info.Expiring)
+}
+
+func TestReapExpired(t *testing.T) {
+	env := newTestServerEnv(t)
+	clock := env.clock.(*tsutil.TestClock)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+	// We run the reaper ourselves (with the test clock).
+	aliceService.reaper.stop()
+
+	channel, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing"})
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	kept, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "kept plans"})
+	require.NoError(t, err)
+	_, err = aliceService.ChannelRetentionSet(ctx, &ChannelRetentionSetRequest{Channel: channel.ID, Retention: int64(time.Hour / time.Millisecond)})
+	require.NoError(t, err)
+	secret, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "secret plans"})
+	require.NoError(t, err)
+
+	search := func(query string) []string {
+		resp, err := aliceService.MessageSearch(ctx, &MessageSearchRequest{Query: query})
+		require.NoError(t, err)
+		out := []string{}
+		for _, r := range resp.Results {
+			out = append(out, r.Message.ID)
+		}
+		return out
+	}
+	postings := func(term string) []string {
+		ids, err := aliceService.searchPostings(ctx, term)
+		require.NoError(t, err)
+		out := []string{}
+		for id := range ids {
+			out = append(out, id)
+		}
+		return out
+	}
+
+	_, err = aliceService.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{kept.Message.ID, secret.Message.ID}, search("plans"))
+	require.Equal(t, []string{secret.Message.ID}, postings("secret"))
+
+	// Not expired yet
+	require.NoError(t, aliceService.reapExpired(ctx))
+	require.Equal(t, []string{secret.Message.ID}, search("secret"))
+
+	clock.Add(2 * time.Hour)
+	require.NoError(t, aliceService.reapExpired(ctx))
+
+	msgs, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel.ID})
+	require.NoError(t, err)
+	for _, msg := range msgs.Messages {
+		require.NotEqual(t, secret.Message.ID, msg.ID)
+	}
+	require.Equal(t, []string{}, search("secret"))
+	require.Equal(t, []string{kept.Message.ID}, search("plans"))
+
+	// Terms are gone from the index
+	require.Equal(t, []string{}, postings("secret"))
+	require.Equal(t, []string{kept.Message.ID}, postings("plans"))
+	doc, err := aliceService.searchDoc(ctx, secret.Message.ID)
+	require.NoError(t, err)
+	require.Nil(t, doc)
+}
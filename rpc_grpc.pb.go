@@ -34,14 +34,48 @@ type RPCClient interface {
 	Channels(ctx context.Context, in *ChannelsRequest, opts ...grpc.CallOption) (*ChannelsResponse, error)
 	ChannelCreate(ctx context.Context, in *ChannelCreateRequest, opts ...grpc.CallOption) (*ChannelCreateResponse, error)
 	ChannelLeave(ctx context.Context, in *ChannelLeaveRequest, opts ...grpc.CallOption) (*ChannelLeaveResponse, error)
+	ChannelJoin(ctx context.Context, in *ChannelJoinRequest, opts ...grpc.CallOption) (*ChannelJoinResponse, error)
 	ChannelRead(ctx context.Context, in *ChannelReadRequest, opts ...grpc.CallOption) (*ChannelReadResponse, error)
 	ChannelUsers(ctx context.Context, in *ChannelUsersRequest, opts ...grpc.CallOption) (*ChannelUsersResponse, error)
 	ChannelUsersAdd(ctx context.Context, in *ChannelUsersAddRequest, opts ...grpc.CallOption) (*ChannelUsersAddResponse, error)
 	ChannelUsersRemove(ctx context.Context, in *ChannelUsersRemoveRequest, opts ...grpc.CallOption) (*ChannelUsersRemoveResponse, error)
+	ChannelInvite(ctx context.Context, in *ChannelInviteRequest, opts ...grpc.CallOption) (*ChannelInviteResponse, error)
+	ChannelInfoSet(ctx context.Context, in *ChannelInfoSetRequest, opts ...grpc.CallOption) (*ChannelInfoSetResponse, error)
+	ChannelRetentionSet(ctx context.Context, in *ChannelRetentionSetRequest, opts ...grpc.CallOption) (*ChannelRetentionSetResponse, error)
+	DirectMessage(ctx context.Context, in *DirectMessageRequest, opts ...grpc.CallOption) (*DirectMessageResponse, error)
+	Users(ctx context.Context, in *UsersRequest, opts ...grpc.CallOption) (*UsersResponse, error)
+	UserInfo(ctx context.Context, in *UserInfoRequest, opts ...grpc.CallOption) (*UserInfoResponse, error)
+	UserWarningDismiss(ctx context.Context, in *UserWarningDismissRequest, opts ...grpc.CallOption) (*UserWarningDismissResponse, error)
+	SafetyNumber(ctx context.Context, in *SafetyNumberRequest, opts ...grpc.CallOption) (*SafetyNumberResponse, error)
+	ContactVerify(ctx context.Context, in *ContactVerifyRequest, opts ...grpc.CallOption) (*ContactVerifyResponse, error)
+	ContactUnverify(ctx context.Context, in *ContactUnverifyRequest, opts ...grpc.CallOption) (*ContactUnverifyResponse, error)
 	// Messages
 	MessagePrepare(ctx context.Context, in *MessagePrepareRequest, opts ...grpc.CallOption) (*MessagePrepareResponse, error)
 	MessageSend(ctx context.Context, in *MessageSendRequest, opts ...grpc.CallOption) (*MessageSendResponse, error)
 	Messages(ctx context.Context, in *MessagesRequest, opts ...grpc.CallOption) (*MessagesResponse, error)
+	MessageResend(ctx context.Context, in *MessageResendRequest, opts ...grpc.CallOption) (*MessageResendResponse, error)
+	MessageDiscard(ctx context.Context, in *MessageDiscardRequest, opts ...grpc.CallOption) (*MessageDiscardResponse, error)
+	MessageEdit(ctx context.Context, in *MessageEditRequest, opts ...grpc.CallOption) (*MessageEditResponse, error)
+	MessageDelete(ctx context.Context, in *MessageDeleteRequest, opts ...grpc.CallOption) (*MessageDeleteResponse, error)
+	MessageThread(ctx context.Context, in *MessageThreadRequest, opts ...grpc.CallOption) (*MessageThreadResponse, error)
+	MessageReact(ctx context.Context, in *MessageReactRequest, opts ...grpc.CallOption) (*MessageReactResponse, error)
+	MessageUnreact(ctx context.Context, in *MessageUnreactRequest, opts ...grpc.CallOption) (*MessageUnreactResponse, error)
+	MessageSearch(ctx context.Context, in *MessageSearchRequest, opts ...grpc.CallOption) (*MessageSearchResponse, error)
+	MessageSchedule(ctx context.Context, in *MessageScheduleRequest, opts ...grpc.CallOption) (*MessageScheduleResponse, error)
+	ScheduledMessages(ctx context.Context, in *ScheduledMessagesRequest, opts ...grpc.CallOption) (*ScheduledMessagesResponse, error)
+	ScheduledCancel(ctx context.Context, in *ScheduledCancelRequest, opts ...grpc.CallOption) (*ScheduledCancelResponse, error)
+	MessagePin(ctx context.Context, in *MessagePinRequest, opts ...grpc.CallOption) (*MessagePinResponse, error)
+	MessageUnpin(ctx context.Context, in *MessageUnpinRequest, opts ...grpc.CallOption) (*MessageUnpinResponse, error)
+	ChannelPins(ctx context.Context, in *ChannelPinsRequest, opts ...grpc.CallOption) (*ChannelPinsResponse, error)
+	MessageSave(ctx context.Context, in *MessageSaveRequest, opts ...grpc.CallOption) (*MessageSaveResponse, error)
+	MessageUnsave(ctx context.Context, in *MessageUnsaveRequest, opts ...grpc.CallOption) (*MessageUnsaveResponse, error)
+	SavedMessages(ctx context.Context, in *SavedMessagesRequest, opts ...grpc.CallOption) (*SavedMessagesResponse, error)
+	// Commands
+	Commands(ctx context.Context, in *CommandsRequest, opts ...grpc.CallOption) (*CommandsResponse, error)
+	// Drafts
+	DraftSet(ctx context.Context, in *DraftSetRequest, opts ...grpc.CallOption) (*DraftSetResponse, error)
+	DraftGet(ctx context.Context, in *DraftGetRequest, opts ...grpc.CallOption) (*DraftGetResponse, error)
+	DraftClear(ctx context.Context, in *DraftClearRequest, opts ...grpc.CallOption) (*DraftClearResponse, error)
 	// Relay
 	Relay(ctx context.Context, in *RelayRequest, opts ...grpc.CallOption) (RPC_RelayClient, error)
 	// DB
@@ -192,6 +226,15 @@ func (c *rPCClient) ChannelLeave(ctx context.Context, in *ChannelLeaveRequest, o
 	return out, nil
 }
 
+func (c *rPCClient) ChannelJoin(ctx context.Context, in *ChannelJoinRequest, opts ...grpc.CallOption) (*ChannelJoinResponse, error) {
+	out := new(ChannelJoinResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ChannelJoin", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *rPCClient) ChannelRead(ctx context.Context, in *ChannelReadRequest, opts ...grpc.CallOption) (*ChannelReadResponse, error) {
 	out := new(ChannelReadResponse)
 	err := c.cc.Invoke(ctx, "/service.RPC/ChannelRead", in, out, opts...)
@@ -228,6 +271,96 @@ func (c *rPCClient) ChannelUsersRemove(ctx context.Context, in *ChannelUsersRemo
 	return out, nil
 }
 
+func (c *rPCClient) ChannelInvite(ctx context.Context, in *ChannelInviteRequest, opts ...grpc.CallOption) (*ChannelInviteResponse, error) {
+	out := new(ChannelInviteResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ChannelInvite", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ChannelInfoSet(ctx context.Context, in *ChannelInfoSetRequest, opts ...grpc.CallOption) (*ChannelInfoSetResponse, error) {
+	out := new(ChannelInfoSetResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ChannelInfoSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ChannelRetentionSet(ctx context.Context, in *ChannelRetentionSetRequest, opts ...grpc.CallOption) (*ChannelRetentionSetResponse, error) {
+	out := new(ChannelRetentionSetResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ChannelRetentionSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) DirectMessage(ctx context.Context, in *DirectMessageRequest, opts ...grpc.CallOption) (*DirectMessageResponse, error) {
+	out := new(DirectMessageResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/DirectMessage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) Users(ctx context.Context, in *UsersRequest, opts ...grpc.CallOption) (*UsersResponse, error) {
+	out := new(UsersResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/Users", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) UserInfo(ctx context.Context, in *UserInfoRequest, opts ...grpc.CallOption) (*UserInfoResponse, error) {
+	out := new(UserInfoResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/UserInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) UserWarningDismiss(ctx context.Context, in *UserWarningDismissRequest, opts ...grpc.CallOption) (*UserWarningDismissResponse, error) {
+	out := new(UserWarningDismissResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/UserWarningDismiss", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) SafetyNumber(ctx context.Context, in *SafetyNumberRequest, opts ...grpc.CallOption) (*SafetyNumberResponse, error) {
+	out := new(SafetyNumberResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/SafetyNumber", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ContactVerify(ctx context.Context, in *ContactVerifyRequest, opts ...grpc.CallOption) (*ContactVerifyResponse, error) {
+	out := new(ContactVerifyResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ContactVerify", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ContactUnverify(ctx context.Context, in *ContactUnverifyRequest, opts ...grpc.CallOption) (*ContactUnverifyResponse, error) {
+	out := new(ContactUnverifyResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ContactUnverify", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *rPCClient) MessagePrepare(ctx context.Context, in *MessagePrepareRequest, opts ...grpc.CallOption) (*MessagePrepareResponse, error) {
 	out := new(MessagePrepareResponse)
 	err := c.cc.Invoke(ctx, "/service.RPC/MessagePrepare", in, out, opts...)
@@ -255,6 +388,195 @@ func (c *rPCClient) Messages(ctx context.Context, in *MessagesRequest, opts ...g
 	return out, nil
 }
 
+func (c *rPCClient) MessageResend(ctx context.Context, in *MessageResendRequest, opts ...grpc.CallOption) (*MessageResendResponse, error) {
+	out := new(MessageResendResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageResend", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageDiscard(ctx context.Context, in *MessageDiscardRequest, opts ...grpc.CallOption) (*MessageDiscardResponse, error) {
+	out := new(MessageDiscardResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageDiscard", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageEdit(ctx context.Context, in *MessageEditRequest, opts ...grpc.CallOption) (*MessageEditResponse, error) {
+	out := new(MessageEditResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageEdit", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageDelete(ctx context.Context, in *MessageDeleteRequest, opts ...grpc.CallOption) (*MessageDeleteResponse, error) {
+	out := new(MessageDeleteResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageDelete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageThread(ctx context.Context, in *MessageThreadRequest, opts ...grpc.CallOption) (*MessageThreadResponse, error) {
+	out := new(MessageThreadResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageThread", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageReact(ctx context.Context, in *MessageReactRequest, opts ...grpc.CallOption) (*MessageReactResponse, error) {
+	out := new(MessageReactResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageReact", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageUnreact(ctx context.Context, in *MessageUnreactRequest, opts ...grpc.CallOption) (*MessageUnreactResponse, error) {
+	out := new(MessageUnreactResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageUnreact", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageSearch(ctx context.Context, in *MessageSearchRequest, opts ...grpc.CallOption) (*MessageSearchResponse, error) {
+	out := new(MessageSearchResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageSearch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageSchedule(ctx context.Context, in *MessageScheduleRequest, opts ...grpc.CallOption) (*MessageScheduleResponse, error) {
+	out := new(MessageScheduleResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageSchedule", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ScheduledMessages(ctx context.Context, in *ScheduledMessagesRequest, opts ...grpc.CallOption) (*ScheduledMessagesResponse, error) {
+	out := new(ScheduledMessagesResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ScheduledMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ScheduledCancel(ctx context.Context, in *ScheduledCancelRequest, opts ...grpc.CallOption) (*ScheduledCancelResponse, error) {
+	out := new(ScheduledCancelResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ScheduledCancel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessagePin(ctx context.Context, in *MessagePinRequest, opts ...grpc.CallOption) (*MessagePinResponse, error) {
+	out := new(MessagePinResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessagePin", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageUnpin(ctx context.Context, in *MessageUnpinRequest, opts ...grpc.CallOption) (*MessageUnpinResponse, error) {
+	out := new(MessageUnpinResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageUnpin", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) ChannelPins(ctx context.Context, in *ChannelPinsRequest, opts ...grpc.CallOption) (*ChannelPinsResponse, error) {
+	out := new(ChannelPinsResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/ChannelPins", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageSave(ctx context.Context, in *MessageSaveRequest, opts ...grpc.CallOption) (*MessageSaveResponse, error) {
+	out := new(MessageSaveResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageSave", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) MessageUnsave(ctx context.Context, in *MessageUnsaveRequest, opts ...grpc.CallOption) (*MessageUnsaveResponse, error) {
+	out := new(MessageUnsaveResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/MessageUnsave", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) SavedMessages(ctx context.Context, in *SavedMessagesRequest, opts ...grpc.CallOption) (*SavedMessagesResponse, error) {
+	out := new(SavedMessagesResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/SavedMessages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) Commands(ctx context.Context, in *CommandsRequest, opts ...grpc.CallOption) (*CommandsResponse, error) {
+	out := new(CommandsResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/Commands", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) DraftSet(ctx context.Context, in *DraftSetRequest, opts ...grpc.CallOption) (*DraftSetResponse, error) {
+	out := new(DraftSetResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/DraftSet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) DraftGet(ctx context.Context, in *DraftGetRequest, opts ...grpc.CallOption) (*DraftGetResponse, error) {
+	out := new(DraftGetResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/DraftGet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rPCClient) DraftClear(ctx context.Context, in *DraftClearRequest, opts ...grpc.CallOption) (*DraftClearResponse, error) {
+	out := new(DraftClearResponse)
+	err := c.cc.Invoke(ctx, "/service.RPC/DraftClear", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *rPCClient) Relay(ctx context.Context, in *RelayRequest, opts ...grpc.CallOption) (RPC_RelayClient, error) {
 	stream, err := c.cc.NewStream(ctx, &_RPC_serviceDesc.Streams[0], "/service.RPC/Relay", opts...)
 	if err != nil {
@@ -326,14 +648,48 @@ type RPCServer interface {
 	Channels(context.Context, *ChannelsRequest) (*ChannelsResponse, error)
 	ChannelCreate(context.Context, *ChannelCreateRequest) (*ChannelCreateResponse, error)
 	ChannelLeave(context.Context, *ChannelLeaveRequest) (*ChannelLeaveResponse, error)
+	ChannelJoin(context.Context, *ChannelJoinRequest) (*ChannelJoinResponse, error)
 	ChannelRead(context.Context, *ChannelReadRequest) (*ChannelReadResponse, error)
 	ChannelUsers(context.Context, *ChannelUsersRequest) (*ChannelUsersResponse, error)
 	ChannelUsersAdd(context.Context, *ChannelUsersAddRequest) (*ChannelUsersAddResponse, error)
 	ChannelUsersRemove(context.Context, *ChannelUsersRemoveRequest) (*ChannelUsersRemoveResponse, error)
+	ChannelInvite(context.Context, *ChannelInviteRequest) (*ChannelInviteResponse, error)
+	ChannelInfoSet(context.Context, *ChannelInfoSetRequest) (*ChannelInfoSetResponse, error)
+	ChannelRetentionSet(context.Context, *ChannelRetentionSetRequest) (*ChannelRetentionSetResponse, error)
+	DirectMessage(context.Context, *DirectMessageRequest) (*DirectMessageResponse, error)
+	Users(context.Context, *UsersRequest) (*UsersResponse, error)
+	UserInfo(context.Context, *UserInfoRequest) (*UserInfoResponse, error)
+	UserWarningDismiss(context.Context, *UserWarningDismissRequest) (*UserWarningDismissResponse, error)
+	SafetyNumber(context.Context, *SafetyNumberRequest) (*SafetyNumberResponse, error)
+	ContactVerify(context.Context, *ContactVerifyRequest) (*ContactVerifyResponse, error)
+	ContactUnverify(context.Context, *ContactUnverifyRequest) (*ContactUnverifyResponse, error)
 	// Messages
 	MessagePrepare(context.Context, *MessagePrepareRequest) (*MessagePrepareResponse, error)
 	MessageSend(context.Context, *MessageSendRequest) (*MessageSendResponse, error)
 	Messages(context.Context, *MessagesRequest) (*MessagesResponse, error)
+	MessageResend(context.Context, *MessageResendRequest) (*MessageResendResponse, error)
+	MessageDiscard(context.Context, *MessageDiscardRequest) (*MessageDiscardResponse, error)
+	MessageEdit(context.Context, *MessageEditRequest) (*MessageEditResponse, error)
+	MessageDelete(context.Context, *MessageDeleteRequest) (*MessageDeleteResponse, error)
+	MessageThread(context.Context, *MessageThreadRequest) (*MessageThreadResponse, error)
+	MessageReact(context.Context, *MessageReactRequest) (*MessageReactResponse, error)
+	MessageUnreact(context.Context, *MessageUnreactRequest) (*MessageUnreactResponse, error)
+	MessageSearch(context.Context, *MessageSearchRequest) (*MessageSearchResponse, error)
+	MessageSchedule(context.Context, *MessageScheduleRequest) (*MessageScheduleResponse, error)
+	ScheduledMessages(context.Context, *ScheduledMessagesRequest) (*ScheduledMessagesResponse, error)
+	ScheduledCancel(context.Context, *ScheduledCancelRequest) (*ScheduledCancelResponse, error)
+	MessagePin(context.Context, *MessagePinRequest) (*MessagePinResponse, error)
+	MessageUnpin(context.Context, *MessageUnpinRequest) (*MessageUnpinResponse, error)
+	ChannelPins(context.Context, *ChannelPinsRequest) (*ChannelPinsResponse, error)
+	MessageSave(context.Context, *MessageSaveRequest) (*MessageSaveResponse, error)
+	MessageUnsave(context.Context, *MessageUnsaveRequest) (*MessageUnsaveResponse, error)
+	SavedMessages(context.Context, *SavedMessagesRequest) (*SavedMessagesResponse, error)
+	// Commands
+	Commands(context.Context, *CommandsRequest) (*CommandsResponse, error)
+	// Drafts
+	DraftSet(context.Context, *DraftSetRequest) (*DraftSetResponse, error)
+	DraftGet(context.Context, *DraftGetRequest) (*DraftGetResponse, error)
+	DraftClear(context.Context, *DraftClearRequest) (*DraftClearResponse, error)
 	// Relay
 	Relay(*RelayRequest, RPC_RelayServer) error
 	// DB
@@ -391,6 +747,9 @@ func (*UnimplementedRPCServer) ChannelCreate(context.Context, *ChannelCreateRequ
 func (*UnimplementedRPCServer) ChannelLeave(context.Context, *ChannelLeaveRequest) (*ChannelLeaveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChannelLeave not implemented")
 }
+func (*UnimplementedRPCServer) ChannelJoin(context.Context, *ChannelJoinRequest) (*ChannelJoinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelJoin not implemented")
+}
 func (*UnimplementedRPCServer) ChannelRead(context.Context, *ChannelReadRequest) (*ChannelReadResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChannelRead not implemented")
 }
@@ -403,6 +762,36 @@ func (*UnimplementedRPCServer) ChannelUsersAdd(context.Context, *ChannelUsersAdd
 func (*UnimplementedRPCServer) ChannelUsersRemove(context.Context, *ChannelUsersRemoveRequest) (*ChannelUsersRemoveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ChannelUsersRemove not implemented")
 }
+func (*UnimplementedRPCServer) ChannelInvite(context.Context, *ChannelInviteRequest) (*ChannelInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelInvite not implemented")
+}
+func (*UnimplementedRPCServer) ChannelInfoSet(context.Context, *ChannelInfoSetRequest) (*ChannelInfoSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelInfoSet not implemented")
+}
+func (*UnimplementedRPCServer) ChannelRetentionSet(context.Context, *ChannelRetentionSetRequest) (*ChannelRetentionSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelRetentionSet not implemented")
+}
+func (*UnimplementedRPCServer) DirectMessage(context.Context, *DirectMessageRequest) (*DirectMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DirectMessage not implemented")
+}
+func (*UnimplementedRPCServer) Users(context.Context, *UsersRequest) (*UsersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Users not implemented")
+}
+func (*UnimplementedRPCServer) UserInfo(context.Context, *UserInfoRequest) (*UserInfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserInfo not implemented")
+}
+func (*UnimplementedRPCServer) UserWarningDismiss(context.Context, *UserWarningDismissRequest) (*UserWarningDismissResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserWarningDismiss not implemented")
+}
+func (*UnimplementedRPCServer) SafetyNumber(context.Context, *SafetyNumberRequest) (*SafetyNumberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SafetyNumber not implemented")
+}
+func (*UnimplementedRPCServer) ContactVerify(context.Context, *ContactVerifyRequest) (*ContactVerifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ContactVerify not implemented")
+}
+func (*UnimplementedRPCServer) ContactUnverify(context.Context, *ContactUnverifyRequest) (*ContactUnverifyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ContactUnverify not implemented")
+}
 func (*UnimplementedRPCServer) MessagePrepare(context.Context, *MessagePrepareRequest) (*MessagePrepareResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MessagePrepare not implemented")
 }
@@ -412,6 +801,69 @@ func (*UnimplementedRPCServer) MessageSend(context.Context, *MessageSendRequest)
 func (*UnimplementedRPCServer) Messages(context.Context, *MessagesRequest) (*MessagesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Messages not implemented")
 }
+func (*UnimplementedRPCServer) MessageResend(context.Context, *MessageResendRequest) (*MessageResendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageResend not implemented")
+}
+func (*UnimplementedRPCServer) MessageDiscard(context.Context, *MessageDiscardRequest) (*MessageDiscardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageDiscard not implemented")
+}
+func (*UnimplementedRPCServer) MessageEdit(context.Context, *MessageEditRequest) (*MessageEditResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageEdit not implemented")
+}
+func (*UnimplementedRPCServer) MessageDelete(context.Context, *MessageDeleteRequest) (*MessageDeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageDelete not implemented")
+}
+func (*UnimplementedRPCServer) MessageThread(context.Context, *MessageThreadRequest) (*MessageThreadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageThread not implemented")
+}
+func (*UnimplementedRPCServer) MessageReact(context.Context, *MessageReactRequest) (*MessageReactResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageReact not implemented")
+}
+func (*UnimplementedRPCServer) MessageUnreact(context.Context, *MessageUnreactRequest) (*MessageUnreactResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageUnreact not implemented")
+}
+func (*UnimplementedRPCServer) MessageSearch(context.Context, *MessageSearchRequest) (*MessageSearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageSearch not implemented")
+}
+func (*UnimplementedRPCServer) MessageSchedule(context.Context, *MessageScheduleRequest) (*MessageScheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageSchedule not implemented")
+}
+func (*UnimplementedRPCServer) ScheduledMessages(context.Context, *ScheduledMessagesRequest) (*ScheduledMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScheduledMessages not implemented")
+}
+func (*UnimplementedRPCServer) ScheduledCancel(context.Context, *ScheduledCancelRequest) (*ScheduledCancelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScheduledCancel not implemented")
+}
+func (*UnimplementedRPCServer) MessagePin(context.Context, *MessagePinRequest) (*MessagePinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessagePin not implemented")
+}
+func (*UnimplementedRPCServer) MessageUnpin(context.Context, *MessageUnpinRequest) (*MessageUnpinResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageUnpin not implemented")
+}
+func (*UnimplementedRPCServer) ChannelPins(context.Context, *ChannelPinsRequest) (*ChannelPinsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChannelPins not implemented")
+}
+func (*UnimplementedRPCServer) MessageSave(context.Context, *MessageSaveRequest) (*MessageSaveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageSave not implemented")
+}
+func (*UnimplementedRPCServer) MessageUnsave(context.Context, *MessageUnsaveRequest) (*MessageUnsaveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MessageUnsave not implemented")
+}
+func (*UnimplementedRPCServer) SavedMessages(context.Context, *SavedMessagesRequest) (*SavedMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SavedMessages not implemented")
+}
+func (*UnimplementedRPCServer) Commands(context.Context, *CommandsRequest) (*CommandsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Commands not implemented")
+}
+func (*UnimplementedRPCServer) DraftSet(context.Context, *DraftSetRequest) (*DraftSetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DraftSet not implemented")
+}
+func (*UnimplementedRPCServer) DraftGet(context.Context, *DraftGetRequest) (*DraftGetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DraftGet not implemented")
+}
+func (*UnimplementedRPCServer) DraftClear(context.Context, *DraftClearRequest) (*DraftClearResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DraftClear not implemented")
+}
 func (*UnimplementedRPCServer) Relay(*RelayRequest, RPC_RelayServer) error {
 	return status.Errorf(codes.Unimplemented, "method Relay not implemented")
 }
@@ -697,6 +1149,24 @@ func _RPC_ChannelLeave_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
+func _RPC_ChannelJoin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelJoinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ChannelJoin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ChannelJoin",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ChannelJoin(ctx, req.(*ChannelJoinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _RPC_ChannelRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChannelReadRequest)
 	if err := dec(in); err != nil {
@@ -769,64 +1239,622 @@ func _RPC_ChannelUsersRemove_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RPC_MessagePrepare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MessagePrepareRequest)
+func _RPC_ChannelInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelInviteRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RPCServer).MessagePrepare(ctx, in)
+		return srv.(RPCServer).ChannelInvite(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/service.RPC/MessagePrepare",
+		FullMethod: "/service.RPC/ChannelInvite",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RPCServer).MessagePrepare(ctx, req.(*MessagePrepareRequest))
+		return srv.(RPCServer).ChannelInvite(ctx, req.(*ChannelInviteRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RPC_MessageSend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MessageSendRequest)
+func _RPC_ChannelInfoSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelInfoSetRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RPCServer).MessageSend(ctx, in)
+		return srv.(RPCServer).ChannelInfoSet(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/service.RPC/MessageSend",
+		FullMethod: "/service.RPC/ChannelInfoSet",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RPCServer).MessageSend(ctx, req.(*MessageSendRequest))
+		return srv.(RPCServer).ChannelInfoSet(ctx, req.(*ChannelInfoSetRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RPC_Messages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(MessagesRequest)
+func _RPC_ChannelRetentionSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelRetentionSetRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(RPCServer).Messages(ctx, in)
+		return srv.(RPCServer).ChannelRetentionSet(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/service.RPC/Messages",
+		FullMethod: "/service.RPC/ChannelRetentionSet",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(RPCServer).Messages(ctx, req.(*MessagesRequest))
+		return srv.(RPCServer).ChannelRetentionSet(ctx, req.(*ChannelRetentionSetRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _RPC_Relay_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(RelayRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
+func _RPC_DirectMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DirectMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).DirectMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/DirectMessage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).DirectMessage(ctx, req.(*DirectMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_Users_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UsersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).Users(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/Users",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).Users(ctx, req.(*UsersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_UserInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).UserInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/UserInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).UserInfo(ctx, req.(*UserInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_UserWarningDismiss_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserWarningDismissRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).UserWarningDismiss(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/UserWarningDismiss",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).UserWarningDismiss(ctx, req.(*UserWarningDismissRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_SafetyNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SafetyNumberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).SafetyNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/SafetyNumber",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).SafetyNumber(ctx, req.(*SafetyNumberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_ContactVerify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactVerifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ContactVerify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ContactVerify",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ContactVerify(ctx, req.(*ContactVerifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_ContactUnverify_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContactUnverifyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ContactUnverify(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ContactUnverify",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ContactUnverify(ctx, req.(*ContactUnverifyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessagePrepare_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessagePrepareRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessagePrepare(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessagePrepare",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessagePrepare(ctx, req.(*MessagePrepareRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageSend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageSendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageSend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageSend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageSend(ctx, req.(*MessageSendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_Messages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).Messages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/Messages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).Messages(ctx, req.(*MessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageResend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageResendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageResend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageResend",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageResend(ctx, req.(*MessageResendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageDiscard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageDiscardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageDiscard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageDiscard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageDiscard(ctx, req.(*MessageDiscardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageEdit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageEditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageEdit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageEdit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageEdit(ctx, req.(*MessageEditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageDelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageDelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageDelete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageDelete(ctx, req.(*MessageDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageThread_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageThreadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageThread(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageThread",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageThread(ctx, req.(*MessageThreadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageReact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageReactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageReact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageReact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageReact(ctx, req.(*MessageReactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageUnreact_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageUnreactRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageUnreact(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageUnreact",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageUnreact(ctx, req.(*MessageUnreactRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageSearch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageSearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageSearch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageSearch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageSearch(ctx, req.(*MessageSearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageSchedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageSchedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageSchedule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageSchedule(ctx, req.(*MessageScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_ScheduledMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduledMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ScheduledMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ScheduledMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ScheduledMessages(ctx, req.(*ScheduledMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_ScheduledCancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduledCancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ScheduledCancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ScheduledCancel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ScheduledCancel(ctx, req.(*ScheduledCancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessagePin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessagePinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessagePin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessagePin",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessagePin(ctx, req.(*MessagePinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageUnpin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageUnpinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageUnpin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageUnpin",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageUnpin(ctx, req.(*MessageUnpinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_ChannelPins_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChannelPinsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).ChannelPins(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/ChannelPins",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).ChannelPins(ctx, req.(*ChannelPinsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageSave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageSaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageSave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageSave",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageSave(ctx, req.(*MessageSaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_MessageUnsave_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MessageUnsaveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).MessageUnsave(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/MessageUnsave",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).MessageUnsave(ctx, req.(*MessageUnsaveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_SavedMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SavedMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).SavedMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/SavedMessages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).SavedMessages(ctx, req.(*SavedMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_Commands_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommandsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).Commands(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/Commands",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).Commands(ctx, req.(*CommandsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_DraftSet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DraftSetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).DraftSet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/DraftSet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).DraftSet(ctx, req.(*DraftSetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_DraftGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DraftGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).DraftGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/DraftGet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).DraftGet(ctx, req.(*DraftGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_DraftClear_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DraftClearRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RPCServer).DraftClear(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/service.RPC/DraftClear",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RPCServer).DraftClear(ctx, req.(*DraftClearRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RPC_Relay_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RelayRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
 	return srv.(RPCServer).Relay(m, &rPCRelayServer{stream})
 }
@@ -944,6 +1972,10 @@ var _RPC_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ChannelLeave",
 			Handler:    _RPC_ChannelLeave_Handler,
 		},
+		{
+			MethodName: "ChannelJoin",
+			Handler:    _RPC_ChannelJoin_Handler,
+		},
 		{
 			MethodName: "ChannelRead",
 			Handler:    _RPC_ChannelRead_Handler,
@@ -960,6 +1992,46 @@ var _RPC_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ChannelUsersRemove",
 			Handler:    _RPC_ChannelUsersRemove_Handler,
 		},
+		{
+			MethodName: "ChannelInvite",
+			Handler:    _RPC_ChannelInvite_Handler,
+		},
+		{
+			MethodName: "ChannelInfoSet",
+			Handler:    _RPC_ChannelInfoSet_Handler,
+		},
+		{
+			MethodName: "ChannelRetentionSet",
+			Handler:    _RPC_ChannelRetentionSet_Handler,
+		},
+		{
+			MethodName: "DirectMessage",
+			Handler:    _RPC_DirectMessage_Handler,
+		},
+		{
+			MethodName: "Users",
+			Handler:    _RPC_Users_Handler,
+		},
+		{
+			MethodName: "UserInfo",
+			Handler:    _RPC_UserInfo_Handler,
+		},
+		{
+			MethodName: "UserWarningDismiss",
+			Handler:    _RPC_UserWarningDismiss_Handler,
+		},
+		{
+			MethodName: "SafetyNumber",
+			Handler:    _RPC_SafetyNumber_Handler,
+		},
+		{
+			MethodName: "ContactVerify",
+			Handler:    _RPC_ContactVerify_Handler,
+		},
+		{
+			MethodName: "ContactUnverify",
+			Handler:    _RPC_ContactUnverify_Handler,
+		},
 		{
 			MethodName: "MessagePrepare",
 			Handler:    _RPC_MessagePrepare_Handler,
@@ -972,6 +2044,90 @@ var _RPC_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Messages",
 			Handler:    _RPC_Messages_Handler,
 		},
+		{
+			MethodName: "MessageResend",
+			Handler:    _RPC_MessageResend_Handler,
+		},
+		{
+			MethodName: "MessageDiscard",
+			Handler:    _RPC_MessageDiscard_Handler,
+		},
+		{
+			MethodName: "MessageEdit",
+			Handler:    _RPC_MessageEdit_Handler,
+		},
+		{
+			MethodName: "MessageDelete",
+			Handler:    _RPC_MessageDelete_Handler,
+		},
+		{
+			MethodName: "MessageThread",
+			Handler:    _RPC_MessageThread_Handler,
+		},
+		{
+			MethodName: "MessageReact",
+			Handler:    _RPC_MessageReact_Handler,
+		},
+		{
+			MethodName: "MessageUnreact",
+			Handler:    _RPC_MessageUnreact_Handler,
+		},
+		{
+			MethodName: "MessageSearch",
+			Handler:    _RPC_MessageSearch_Handler,
+		},
+		{
+			MethodName: "MessageSchedule",
+			Handler:    _RPC_MessageSchedule_Handler,
+		},
+		{
+			MethodName: "ScheduledMessages",
+			Handler:    _RPC_ScheduledMessages_Handler,
+		},
+		{
+			MethodName: "ScheduledCancel",
+			Handler:    _RPC_ScheduledCancel_Handler,
+		},
+		{
+			MethodName: "MessagePin",
+			Handler:    _RPC_MessagePin_Handler,
+		},
+		{
+			MethodName: "MessageUnpin",
+			Handler:    _RPC_MessageUnpin_Handler,
+		},
+		{
+			MethodName: "ChannelPins",
+			Handler:    _RPC_ChannelPins_Handler,
+		},
+		{
+			MethodName: "MessageSave",
+			Handler:    _RPC_MessageSave_Handler,
+		},
+		{
+			MethodName: "MessageUnsave",
+			Handler:    _RPC_MessageUnsave_Handler,
+		},
+		{
+			MethodName: "SavedMessages",
+			Handler:    _RPC_SavedMessages_Handler,
+		},
+		{
+			MethodName: "Commands",
+			Handler:    _RPC_Commands_Handler,
+		},
+		{
+			MethodName: "DraftSet",
+			Handler:    _RPC_DraftSet_Handler,
+		},
+		{
+			MethodName: "DraftGet",
+			Handler:    _RPC_DraftGet_Handler,
+		},
+		{
+			MethodName: "DraftClear",
+			Handler:    _RPC_DraftClear_Handler,
+		},
 		{
 			MethodName: "Collections",
 			Handler:    _RPC_Collections_Handler,
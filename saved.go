@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/pkg/errors"
+)
+
+// savedMessage is a message we saved (bookmarked) for ourselves, at
+// /saved/{id}. Other users in the channel don't see it.
+type savedMessage struct {
+	ID      string  `json:"id"`
+	Channel keys.ID `json:"channel"`
+	SavedAt int64   `json:"savedAt"`
+}
+
+// savedMessages returns saved messages for a channel, or all if channel is
+// empty, most recently saved first.
+func (s *service) savedMessages(ctx context.Context, channel keys.ID) ([]*savedMessage, error) {
+	docs, err := s.channelDocuments(ctx, "saved", channel)
+	if err != nil {
+		return nil, err
+	}
+	out := []*savedMessage{}
+	for _, doc := range docs {
+		var sm savedMessage
+		if err := doc.To(&sm); err != nil {
+			return nil, err
+		}
+		out = append(out, &sm)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].SavedAt > out[j].SavedAt
+	})
+	return out, nil
+}
+
+// savedIDs returns the IDs of saved messages.
+func (s *service) savedIDs(ctx context.Context) (map[string]bool, error) {
+	sms, err := s.savedMessages(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for _, sm := range sms {
+		out[sm.ID] = true
+	}
+	return out, nil
+}
+
+// deleteSavedMessages removes saved messages for a channel.
+func (s *service) deleteSavedMessages(ctx context.Context, channel keys.ID) error {
+	sms, err := s.savedMessages(ctx, channel)
+	if err != nil {
+		return err
+	}
+	for _, sm := range sms {
+		if _, err := s.db.Delete(ctx, dstore.Path("saved", sm.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MessageSave (RPC) adds a message to our saved messages.
+func (s *service) MessageSave(ctx context.Context, req *MessageSaveRequest) (*MessageSaveResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	if req.ID == "" {
+		return nil, errors.Errorf("no message id specified")
+	}
+	msg, err := s.findMessage(channel, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, errors.Errorf("message not found")
+	}
+	exists, err := s.db.Exists(ctx, dstore.Path("saved", msg.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		sm := &savedMessage{ID: msg.ID, Channel: channel, SavedAt: s.clock.NowMillis()}
+		if err := s.db.Set(ctx, dstore.Path("saved", sm.ID), dstore.From(sm)); err != nil {
+			return nil, err
+		}
+	}
+	return &MessageSaveResponse{}, nil
+}
+
+// MessageUnsave (RPC) removes a message from our saved messages.
+func (s *service) MessageUnsave(ctx context.Context, req *MessageUnsaveRequest) (*MessageUnsaveResponse, error) {
+	if req.ID == "" {
+		return nil, errors.Errorf("no message id specified")
+	}
+	ok, err := s.db.Delete(ctx, dstore.Path("saved", req.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.Errorf("saved message not found")
+	}
+	return &MessageUnsaveResponse{}, nil
+}
+
+// SavedMessages (RPC) lists our saved messages, most recently saved first.
+// Messages that were deleted or expired are skipped.
+func (s *service) SavedMessages(ctx context.Context, req *SavedMessagesRequest) (*SavedMessagesResponse, error) {
+	var channel keys.ID
+	if req.Channel != "" {
+		cid, err := keys.ParseID(req.Channel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid channel")
+		}
+		channel = cid
+	}
+	sms, err := s.savedMessages(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	now := s.clock.NowMillis()
+
+	// Convert by channel, since messages share the channel aggregate.
+	channels := map[keys.ID]bool{}
+	for _, sm := range sms {
+		channels[sm.Channel] = true
+	}
+	converted := map[string]*Message{}
+	names := map[keys.ID]string{}
+	for cid := range channels {
+		agg, err := s.channelAggregate(cid)
+		if err != nil {
+			return nil, err
+		}
+		msgs := []*api.Message{}
+		for _, sm := range sms {
+			if sm.Channel != cid {
+				continue
+			}
+			msg := agg.message(sm.ID)
+			if msg == nil || agg.expired(sm.ID, now) || agg.deleted(sm.ID) {
+				continue
+			}
+			msgs = append(msgs, msg)
+		}
+		rmsgs, err := s.messagesToRPC(ctx, msgs, agg)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range rmsgs {
+			converted[m.ID] = m
+		}
+		name, err := s.channelName(ctx, cid)
+		if err != nil {
+			logger.Warningf("Saved messages in unknown channel %s: %v", cid, err)
+		}
+		names[cid] = name
+	}
+
+	out := make([]*SavedMessage, 0, len(sms))
+	for _, sm := range sms {
+		m, ok := converted[sm.ID]
+		if !ok {
+			continue
+		}
+		out = append(out, &SavedMessage{
+			Channel:     sm.Channel.String(),
+			ChannelName: names[sm.Channel],
+			Message:     m,
+			SavedAt:     sm.SavedAt,
+		})
+	}
+	return &SavedMessagesResponse{Saved: out}, nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/encoding"
+	"github.com/pkg/errors"
+)
+
+// scheduleInterval is how often we check for scheduled messages that are due.
+const scheduleInterval = 15 * time.Second
+
+// scheduledMessage is a message to send later, at /scheduled/{id}.
+type scheduledMessage struct {
+	ID        string  `json:"id"`
+	Channel   keys.ID `json:"channel"`
+	Text      string  `json:"text"`
+	SendAt    int64   `json:"sendAt"`
+	CreatedAt int64   `json:"createdAt"`
+}
+
+func (m *scheduledMessage) toRPC() *ScheduledMessage {
+	return &ScheduledMessage{
+		ID:        m.ID,
+		Channel:   m.Channel.String(),
+		Text:      m.Text,
+		SendAt:    m.SendAt,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// scheduler runs fn when started, every interval, and when poked, until
+// stopped. Whether anything is due is up to fn (using the service clock).
+type scheduler struct {
+	sync.Mutex
+	interval time.Duration
+	cancel   func()
+	done     chan struct{}
+	wake     chan struct{}
+}
+
+func newScheduler(interval time.Duration) *scheduler {
+	return &scheduler{
+		interval: interval,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (c *scheduler) start(fn func(ctx context.Context) error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go c.run(ctx, fn, c.done)
+}
+
+func (c *scheduler) run(ctx context.Context, fn func(ctx context.Context) error, done chan struct{}) {
+	defer close(done)
+	for {
+		// Also runs for anything that was due while we were stopped.
+		if err := fn(ctx); err != nil {
+			logger.Warningf("Scheduler failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		case <-time.After(c.interval):
+		}
+	}
+}
+
+// stop the scheduler, and wait for fn if it's running (since we close the db
+// on lock).
+func (c *scheduler) stop() {
+	c.Lock()
+	defer c.Unlock()
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+	c.cancel = nil
+	c.done = nil
+}
+
+// poke runs fn now, if started.
+func (c *scheduler) poke() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// scheduledDue returns messages that are due at now.
+func scheduledDue(sms []*scheduledMessage, now int64) []*scheduledMessage {
+	out := []*scheduledMessage{}
+	for _, sm := range sms {
+		if sm.SendAt <= now {
+			out = append(out, sm)
+		}
+	}
+	return out
+}
+
+// scheduledMessages returns scheduled messages for a channel, or all if
+// channel is empty, ordered by send time.
+func (s *service) scheduledMessages(ctx context.Context, channel keys.ID) ([]*scheduledMessage, error) {
+	docs, err := s.channelDocuments(ctx, "scheduled", channel)
+	if err != nil {
+		return nil, err
+	}
+	out := []*scheduledMessage{}
+	for _, doc := range docs {
+		var sm scheduledMessage
+		if err := doc.To(&sm); err != nil {
+			return nil, err
+		}
+		out = append(out, &sm)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].SendAt < out[j].SendAt
+	})
+	return out, nil
+}
+
+// sendScheduled sends scheduled messages that are due. A message that fails
+// doesn't stop the others, the first error is returned.
+func (s *service) sendScheduled(ctx context.Context) error {
+	sms, err := s.scheduledMessages(ctx, "")
+	if err != nil {
+		return err
+	}
+	var sendErr error
+	for _, sm := range scheduledDue(sms, s.clock.NowMillis()) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.sendScheduledMessage(ctx, sm); err != nil {
+			logger.Warningf("Failed to send scheduled message %s: %v", sm.ID, err)
+			if sendErr == nil {
+				sendErr = errors.Wrapf(err, "failed to send scheduled message %s", sm.ID)
+			}
+		}
+	}
+	return sendErr
+}
+
+// sendScheduledMessage moves a scheduled message to the outbox and sends it
+// from there, so if the send fails, it's retried like any other message.
+// The scheduled message is removed first, and only sent if it was still
+// there, so one that was canceled (or already sent) in the meantime isn't.
+func (s *service) sendScheduledMessage(ctx context.Context, sm *scheduledMessage) error {
+	account, err := s.account(true)
+	if err != nil {
+		return err
+	}
+	ok, err := s.db.Delete(ctx, dstore.Path("scheduled", sm.ID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		logger.Debugf("Scheduled message %s was canceled", sm.ID)
+		return nil
+	}
+	logger.Debugf("Sending scheduled message %s", sm.ID)
+	msg := api.NewMessage(sm.Channel, account.ID).
+		WithText(sm.Text).
+		WithTimestamp(s.clock.NowMillis())
+	msg.ID = sm.ID
+
+	om, err := s.outboxAdd(ctx, msg, nil)
+	if err != nil {
+		return err
+	}
+	return s.outboxSend(ctx, om)
+}
+
+// deleteScheduledMessages removes scheduled messages for a channel.
+func (s *service) deleteScheduledMessages(ctx context.Context, channel keys.ID) error {
+	sms, err := s.scheduledMessages(ctx, channel)
+	if err != nil {
+		return err
+	}
+	for _, sm := range sms {
+		if _, err := s.db.Delete(ctx, dstore.Path("scheduled", sm.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MessageSchedule (RPC) saves a message to send later.
+func (s *service) MessageSchedule(ctx context.Context, req *MessageScheduleRequest) (*MessageScheduleResponse, error) {
+	channel, err := keys.ParseID(req.Channel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid channel")
+	}
+	text := processText(req.Text)
+	if text == "" {
+		return nil, errors.Errorf("no text specified")
+	}
+	if strings.HasPrefix(text, "/") {
+		return nil, errors.Errorf("can't schedule a command")
+	}
+	now := s.clock.NowMillis()
+	if req.SendAt <= now {
+		return nil, errors.Errorf("send time is in the past")
+	}
+	if _, err := s.keyring.Key(channel); err != nil {
+		return nil, err
+	}
+
+	sm := &scheduledMessage{
+		ID:        encoding.MustEncode(keys.RandBytes(32), encoding.Base62),
+		Channel:   channel,
+		Text:      text,
+		SendAt:    req.SendAt,
+		CreatedAt: now,
+	}
+	logger.Debugf("Scheduling message %s", sm.ID)
+	if err := s.db.Set(ctx, dstore.Path("scheduled", sm.ID), dstore.From(sm)); err != nil {
+		return nil, err
+	}
+	s.scheduler.poke()
+	return &MessageScheduleResponse{Scheduled: sm.toRPC()}, nil
+}
+
+// ScheduledMessages (RPC) lists scheduled messages.
+func (s *service) ScheduledMessages(ctx context.Context, req *ScheduledMessagesRequest) (*ScheduledMessagesResponse, error) {
+	var channel keys.ID
+	if req.Channel != "" {
+		cid, err := keys.ParseID(req.Channel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid channel")
+		}
+		channel = cid
+	}
+	sms, err := s.scheduledMessages(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ScheduledMessage, 0, len(sms))
+	for _, sm := range sms {
+		out = append(out, sm.toRPC())
+	}
+	return &ScheduledMessagesResponse{Scheduled: out}, nil
+}
+
+// ScheduledCancel (RPC) removes a scheduled message (that wasn't sent yet).
+func (s *service) ScheduledCancel(ctx context.Context, req *ScheduledCancelRequest) (*ScheduledCancelResponse, error) {
+	if req.ID == "" {
+		return nil, errors.Errorf("no id specified")
+	}
+	ok, err := s.db.Delete(ctx, dstore.Path("scheduled", req.ID))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.Errorf("scheduled message not found")
+	}
+	return &ScheduledCancelResponse{}, nil
+}
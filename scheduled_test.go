@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledDue(t *testing.T) {
+	clock := tsutil.NewTestClock()
+	now := clock.NowMillis()
+	sms := []*scheduledMessage{
+		{ID: "1", SendAt: now + int64(time.Minute/time.Millisecond)},
+		{ID: "2", SendAt: now + int64(time.Hour/time.Millisecond)},
+	}
+	require.Equal(t, 0, len(scheduledDue(sms, clock.NowMillis())))
+
+	clock.Add(time.Minute)
+	due := scheduledDue(sms, clock.NowMillis())
+	require.Equal(t, 1, len(due))
+	require.Equal(t, "1", due[0].ID)
+
+	// Daemon was down
+	clock.Add(24 * time.Hour)
+	require.Equal(t, 2, len(scheduledDue(sms, clock.NowMillis())))
+}
+
+func TestScheduler(t *testing.T) {
+	c := newScheduler(time.Hour)
+	sent := make(chan struct{}, 10)
+	send := func(ctx context.Context) error {
+		sent <- struct{}{}
+		return nil
+	}
+
+	// Sends on start
+	c.start(send)
+	<-sent
+	// and when poked
+	c.poke()
+	<-sent
+	c.stop()
+
+	c.poke()
+	select {
+	case <-sent:
+		t.Fatal("sent after stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Sends on (re)start, for anything due while we were stopped
+	c.start(send)
+	<-sent
+	c.stop()
+}
+
+func TestScheduledMessages(t *testing.T) {
+	env := newTestServerEnv(t)
+	clock := env.clock.(*tsutil.TestClock)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+	// We send scheduled messages ourselves (with the test clock).
+	aliceService.scheduler.stop()
+
+	testing1, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing1"})
+	require.NoError(t, err)
+	testing2, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing2"})
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	minute := int64(time.Minute / time.Millisecond)
+	_, err = aliceService.MessageSchedule(ctx, &MessageScheduleRequest{Channel: testing1.ID, Text: "hi", SendAt: clock.NowMillis() - minute})
+	require.EqualError(t, err, "send time is in the past")
+	_, err = aliceService.MessageSchedule(ctx, &MessageScheduleRequest{Channel: testing1.ID, Text: "/help", SendAt: clock.NowMillis() + minute})
+	require.EqualError(t, err, "can't schedule a command")
+	_, err = aliceService.MessageSchedule(ctx, &MessageScheduleRequest{Channel: testing1.ID, SendAt: clock.NowMillis() + minute})
+	require.EqualError(t, err, "no text specified")
+
+	schedule := func(channel string, text string, in time.Duration) *ScheduledMessage {
+		resp, err := aliceService.MessageSchedule(ctx, &MessageScheduleRequest{Channel: channel, Text: text, SendAt: tsutil.Millis(clock.Now().Add(in))})
+		require.NoError(t, err)
+		return resp.Scheduled
+	}
+	later := schedule(testing1.ID, "later", time.Hour)
+	soon := schedule(testing1.ID, "soon", time.Minute)
+	other := schedule(testing2.ID, "other", time.Minute)
+	canceled := schedule(testing2.ID, "canceled", time.Minute)
+
+	// List (by send time)
+	list, err := aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{Channel: testing1.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list.Scheduled))
+	require.Equal(t, soon.ID, list.Scheduled[0].ID)
+	require.Equal(t, later.ID, list.Scheduled[1].ID)
+	list, err = aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{Channel: testing2.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list.Scheduled))
+	require.Equal(t, other.ID, list.Scheduled[0].ID)
+	list, err = aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 4, len(list.Scheduled))
+
+	// Cancel
+	_, err = aliceService.ScheduledCancel(ctx, &ScheduledCancelRequest{ID: canceled.ID})
+	require.NoError(t, err)
+	_, err = aliceService.ScheduledCancel(ctx, &ScheduledCancelRequest{ID: canceled.ID})
+	require.EqualError(t, err, "scheduled message not found")
+
+	// Nothing due yet
+	require.NoError(t, aliceService.sendScheduled(ctx))
+	list, err = aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(list.Scheduled))
+
+	// Due messages are sent
+	clock.Add(2 * time.Minute)
+	require.NoError(t, aliceService.sendScheduled(ctx))
+	list, err = aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list.Scheduled))
+	require.Equal(t, later.ID, list.Scheduled[0].ID)
+
+	// Canceled after it was listed as due, isn't sent
+	raced := schedule(testing1.ID, "raced", time.Minute)
+	sms, err := aliceService.scheduledMessages(ctx, "")
+	require.NoError(t, err)
+	_, err = aliceService.ScheduledCancel(ctx, &ScheduledCancelRequest{ID: raced.ID})
+	require.NoError(t, err)
+	for _, sm := range sms {
+		if sm.ID == raced.ID {
+			require.NoError(t, aliceService.sendScheduledMessage(ctx, sm))
+		}
+	}
+
+	texts := func(channel string) []string {
+		msgs, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel, Update: true})
+		require.NoError(t, err)
+		out := []string{}
+		for _, msg := range msgs.Messages {
+			require.Equal(t, MessageSent, msg.Status)
+			out = append(out, msg.Text...)
+		}
+		return out
+	}
+	require.Equal(t, []string{"soon"}, texts(testing1.ID))
+	require.Equal(t, []string{"other"}, texts(testing2.ID))
+
+	// If sending fails, the message goes to the outbox to retry
+	aliceServiceEnv.getChillAppEnv.closeFn()
+	clock.Add(time.Hour)
+	require.NoError(t, aliceService.sendScheduled(ctx))
+	list, err = aliceService.ScheduledMessages(ctx, &ScheduledMessagesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 0, len(list.Scheduled))
+	cid, err := keys.ParseID(testing1.ID)
+	require.NoError(t, err)
+	oms, err := aliceService.outboxMessages(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(oms))
+	require.Equal(t, later.ID, oms[0].ID)
+	require.Equal(t, MessagePending, oms[0].Status)
+	require.Equal(t, 1, oms[0].Attempts)
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/dstore/events"
+	"github.com/pkg/errors"
+)
+
+// Search is local, so messages and queries never leave this device.
+//
+// /search/{msg}     searchDoc for a message.
+// /sterms/{term}    Postings, message ID to channel ID.
+// /sindex/{channel} Remote index of the last indexed message in a channel.
+//
+// When a message is edited or deleted, or we leave the channel, it's removed
+// from the postings for its (old) terms, so its words don't stay in the db.
+// Results are still checked against the search doc at query time.
+//
+// Channels are pulled (and indexed) at the same time, and removing from a
+// posting rewrites it, so changes to the index hold searchMtx.
+
+const searchSnippetLength = 160
+const searchDefaultLimit = 50
+
+type searchDoc struct {
+	ID          string  `json:"id"`
+	Channel     keys.ID `json:"channel"`
+	Sender      keys.ID `json:"sender"`
+	Text        string  `json:"text"`
+	Parent      string  `json:"parent,omitempty"`
+	Timestamp   int64   `json:"ts"`
+	RemoteIndex int64   `json:"ridx"`
+}
+
+type searchIndex struct {
+	Index int64 `json:"index"`
+}
+
+// indexChannel adds messages in a channel to the search index, from where we
+// last left off.
+func (s *service) indexChannel(ctx context.Context, cid keys.ID) error {
+	s.searchMtx.Lock()
+	defer s.searchMtx.Unlock()
+	var si searchIndex
+	if _, err := s.db.Load(ctx, dstore.Path("sindex", cid), &si); err != nil {
+		return err
+	}
+	msgs, err := s.messenger.MessagesFrom(cid, si.Index, events.Ascending, 0)
+	if err != nil {
+		return err
+	}
+	index := si.Index
+	count := 0
+	for _, msg := range msgs {
+		if err := s.indexMessage(ctx, msg); err != nil {
+			return err
+		}
+		index = msg.RemoteIndex
+		count++
+	}
+	if index == si.Index {
+		return nil
+	}
+	logger.Debugf("Indexed %d message(s) for %s", count, cid)
+	return s.db.Set(ctx, dstore.Path("sindex", cid), dstore.From(searchIndex{Index: index}))
+}
+
+// unindexChannel removes messages in a channel from the search index.
+func (s *service) unindexChannel(ctx context.Context, cid keys.ID) error {
+	s.searchMtx.Lock()
+	defer s.searchMtx.Unlock()
+	docs, err := s.channelDocuments(ctx, "search", cid)
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		var doc searchDoc
+		if err := d.To(&doc); err != nil {
+			return err
+		}
+		if err := s.removeSearchDoc(ctx, &doc); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Delete(ctx, dstore.Path("sindex", cid))
+	return err
+}
+
+// unindexMessages removes messages from the search index, for example when
+// they expire.
+func (s *service) unindexMessages(ctx context.Context, ids []string) error {
+	s.searchMtx.Lock()
+	defer s.searchMtx.Unlock()
+	for _, id := range ids {
+		doc, err := s.searchDoc(ctx, id)
+		if err != nil {
+			return err
+		}
+		if doc == nil {
+			continue
+		}
+		if err := s.removeSearchDoc(ctx, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) indexMessage(ctx context.Context, msg *api.Message) error {
+	if msg.Command != nil {
+		if edit := msg.Command.MessageEdit; edit != nil {
+			doc, err := s.searchDoc(ctx, edit.ID)
+			if err != nil {
+				return err
+			}
+			if doc == nil || doc.Sender != msg.Sender {
+				return nil
+			}
+			if err := s.removePostings(ctx, doc); err != nil {
+				return err
+			}
+			doc.Text = edit.Text
+			return s.indexSearchDoc(ctx, doc)
+		}
+		if del := msg.Command.MessageDelete; del != nil {
+			doc, err := s.searchDoc(ctx, del.ID)
+			if err != nil {
+				return err
+			}
+			if doc == nil || doc.Sender != msg.Sender {
+				return nil
+			}
+			return s.removeSearchDoc(ctx, doc)
+		}
+		return nil
+	}
+	if msg.Text == "" {
+		return nil
+	}
+	return s.indexSearchDoc(ctx, &searchDoc{
+		ID:          msg.ID,
+		Channel:     msg.Channel,
+		Sender:      msg.Sender,
+		Text:        msg.Text,
+		Parent:      msg.Parent,
+		Timestamp:   msg.Timestamp,
+		RemoteIndex: msg.RemoteIndex,
+	})
+}
+
+func (s *service) indexSearchDoc(ctx context.Context, doc *searchDoc) error {
+	if err := s.db.Set(ctx, dstore.Path("search", doc.ID), dstore.From(doc)); err != nil {
+		return err
+	}
+	for _, term := range searchTerms(doc.Text) {
+		posting := map[string]interface{}{doc.ID: doc.Channel.String()}
+		if err := s.db.Set(ctx, dstore.Path("sterms", term), posting, dstore.MergeAll()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSearchDoc removes a message from the search index.
+func (s *service) removeSearchDoc(ctx context.Context, doc *searchDoc) error {
+	if err := s.removePostings(ctx, doc); err != nil {
+		return err
+	}
+	_, err := s.db.Delete(ctx, dstore.Path("search", doc.ID))
+	return err
+}
+
+// removePostings removes the message from the postings for terms in its
+// (indexed) text.
+func (s *service) removePostings(ctx context.Context, doc *searchDoc) error {
+	for _, term := range searchTerms(doc.Text) {
+		path := dstore.Path("sterms", term)
+		d, err := s.db.Get(ctx, path)
+		if err != nil {
+			return err
+		}
+		if d == nil {
+			continue
+		}
+		var posting map[string]string
+		if err := d.To(&posting); err != nil {
+			return err
+		}
+		if _, ok := posting[doc.ID]; !ok {
+			continue
+		}
+		delete(posting, doc.ID)
+		if len(posting) == 0 {
+			if _, err := s.db.Delete(ctx, path); err != nil {
+				return err
+			}
+			continue
+		}
+		values := make(map[string]interface{}, len(posting))
+		for id, channel := range posting {
+			values[id] = channel
+		}
+		if err := s.db.Set(ctx, path, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) searchDoc(ctx context.Context, id string) (*searchDoc, error) {
+	var doc searchDoc
+	ok, err := s.db.Load(ctx, dstore.Path("search", id), &doc)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &doc, nil
+}
+
+// searchPostings returns message IDs for terms starting with prefix.
+func (s *service) searchPostings(ctx context.Context, prefix string) (map[string]bool, error) {
+	docs, err := s.db.Documents(ctx, dstore.Path("sterms"), dstore.Prefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]bool{}
+	for _, doc := range docs {
+		var posting map[string]string
+		if err := doc.To(&posting); err != nil {
+			return nil, err
+		}
+		for id := range posting {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+// MessageSearch (RPC) searches messages in the local index.
+func (s *service) MessageSearch(ctx context.Context, req *MessageSearchRequest) (*MessageSearchResponse, error) {
+	terms := searchTerms(req.Query)
+	if len(terms) == 0 {
+		return nil, errors.Errorf("no query specified")
+	}
+	var channel keys.ID
+	if req.Channel != "" {
+		cid, err := keys.ParseID(req.Channel)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid channel")
+		}
+		channel = cid
+	}
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = searchDefaultLimit
+	}
+
+	// Messages matching all terms (as prefix).
+	var ids map[string]bool
+	for _, term := range terms {
+		postings, err := s.searchPostings(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+		if ids == nil {
+			ids = postings
+			continue
+		}
+		for id := range ids {
+			if !postings[id] {
+				delete(ids, id)
+			}
+		}
+	}
+
+	docs := []*searchDoc{}
+	for id := range ids {
+		doc, err := s.searchDoc(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			continue
+		}
+		if channel != "" && doc.Channel != channel {
+			continue
+		}
+		if req.From != 0 && doc.Timestamp < req.From {
+			continue
+		}
+		if req.To != 0 && doc.Timestamp > req.To {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].Timestamp > docs[j].Timestamp
+	})
+
+	results := []*MessageSearchResult{}
+	for _, doc := range docs {
+		if len(results) >= limit {
+			break
+		}
+		// The index may be stale for edited messages.
+		snippet, highlights, ok := searchSnippet(doc.Text, terms)
+		if !ok {
+			continue
+		}
+		sender, err := s.userName(ctx, doc.Sender)
+		if err != nil {
+			return nil, err
+		}
+		if req.Sender != "" && req.Sender != sender && req.Sender != doc.Sender.String() {
+			continue
+		}
+		results = append(results, &MessageSearchResult{
+			Channel: doc.Channel.String(),
+			Message: &Message{
+				ID:        doc.ID,
+				Sender:    sender,
+				Text:      []string{doc.Text},
+				CreatedAt: doc.Timestamp,
+				Parent:    doc.Parent,
+			},
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+	return &MessageSearchResponse{Results: results}, nil
+}
+
+func isSearchSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// searchTerms returns unique lowercase words in text.
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), isSearchSeparator)
+	seen := map[string]bool{}
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		if seen[w] || utf8.RuneCountInString(w) > 64 {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	return out
+}
+
+// searchSnippet returns a snippet of text around the first match, with
+// highlights for words starting with any of the terms. Returns false if text
+// doesn't match all the terms.
+func searchSnippet(text string, terms []string) (string, []*SearchHighlight, bool) {
+	runes := []rune(text)
+	type match struct{ start, length int }
+	matches := []match{}
+	matched := map[string]bool{}
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && !isSearchSeparator(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		word := strings.ToLower(string(runes[start:i]))
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) {
+				matched[term] = true
+				matches = append(matches, match{start, utf8.RuneCountInString(term)})
+				break
+			}
+		}
+		start = -1
+	}
+	if len(matched) != len(terms) {
+		return "", nil, false
+	}
+
+	from, to := 0, len(runes)
+	if len(runes) > searchSnippetLength {
+		from = matches[0].start - searchSnippetLength/4
+		if from < 0 {
+			from = 0
+		}
+		to = from + searchSnippetLength
+		if to > len(runes) {
+			to = len(runes)
+			from = to - searchSnippetLength
+		}
+	}
+	highlights := []*SearchHighlight{}
+	for _, m := range matches {
+		if m.start < from || m.start+m.length > to {
+			continue
+		}
+		highlights = append(highlights, &SearchHighlight{Start: int32(m.start - from), Length: int32(m.length)})
+	}
+	return string(runes[from:to]), highlights, true
+}
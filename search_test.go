@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTerms(t *testing.T) {
+	require.Equal(t, []string{"hello", "world", "café"}, searchTerms("Hello, world! hello CAFÉ"))
+	require.Equal(t, []string{}, searchTerms(" ... "))
+}
+
+func TestSearchSnippet(t *testing.T) {
+	snippet, highlights, ok := searchSnippet("Meet at the Café tomorrow", []string{"caf", "tom"})
+	require.True(t, ok)
+	require.Equal(t, "Meet at the Café tomorrow", snippet)
+	require.Equal(t, 2, len(highlights))
+	require.Equal(t, int32(12), highlights[0].Start)
+	require.Equal(t, int32(3), highlights[0].Length)
+	require.Equal(t, int32(17), highlights[1].Start)
+
+	// All terms must match
+	_, _, ok = searchSnippet("Meet at the Café", []string{"caf", "tom"})
+	require.False(t, ok)
+
+	// Long text is cut around the first match
+	long := ""
+	for i := 0; i < 100; i++ {
+		long += "abc "
+	}
+	long += "needle"
+	snippet, highlights, ok = searchSnippet(long, []string{"needle"})
+	require.True(t, ok)
+	require.Equal(t, searchSnippetLength, len([]rune(snippet)))
+	require.Equal(t, 1, len(highlights))
+	require.Equal(t, "needle", string([]rune(snippet)[highlights[0].Start:highlights[0].Start+highlights[0].Length]))
+}
+
+func TestMessageSearch(t *testing.T) {
+	env := newTestServerEnv(t)
+	clock := env.clock.(*tsutil.TestClock)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	ops, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "ops"})
+	require.NoError(t, err)
+	other, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "other"})
+	require.NoError(t, err)
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	_, err = bobService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	send := func(service *service, channel string, text string) string {
+		resp, err := service.MessageSend(ctx, &MessageSendRequest{Channel: channel, Text: text})
+		require.NoError(t, err)
+		return resp.Message.ID
+	}
+	search := func(req *MessageSearchRequest) []string {
+		resp, err := aliceService.MessageSearch(ctx, req)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, r := range resp.Results {
+			ids = append(ids, r.Message.ID)
+		}
+		return ids
+	}
+	postings := func(term string) []string {
+		ids, err := aliceService.searchPostings(ctx, term)
+		require.NoError(t, err)
+		out := []string{}
+		for id := range ids {
+			out = append(out, id)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	a1 := send(aliceService, ops.ID, "Deploy with the runbook")
+	clock.Add(time.Hour)
+	from := clock.NowMillis()
+	b1 := send(bobService, ops.ID, "runbook link")
+	a2 := send(aliceService, other.ID, "old runbook")
+
+	// Indexed when pulled
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{a2, b1, a1}, search(&MessageSearchRequest{Query: "runbook"}))
+	require.Equal(t, []string{a1}, search(&MessageSearchRequest{Query: "deploy runb"}))
+	require.Equal(t, []string{b1, a1}, search(&MessageSearchRequest{Query: "runbook", Channel: ops.ID}))
+	require.Equal(t, []string{b1}, search(&MessageSearchRequest{Query: "runbook", Sender: "bob"}))
+	require.Equal(t, []string{a2, b1}, search(&MessageSearchRequest{Query: "runbook", From: from}))
+	require.Equal(t, []string{a1}, search(&MessageSearchRequest{Query: "runbook", To: from}))
+	require.Equal(t, []string{a2}, search(&MessageSearchRequest{Query: "runbook", Limit: 1}))
+	_, err = aliceService.MessageSearch(ctx, &MessageSearchRequest{Query: " "})
+	require.EqualError(t, err, "no query specified")
+
+	// Edited and deleted messages are removed from the postings.
+	_, err = bobService.Messages(ctx, &MessagesRequest{Channel: ops.ID, Update: true})
+	require.NoError(t, err)
+	_, err = aliceService.MessageEdit(ctx, &MessageEditRequest{Channel: ops.ID, ID: a1, Text: "Deploy now"})
+	require.NoError(t, err)
+	_, err = bobService.MessageDelete(ctx, &MessageDeleteRequest{Channel: ops.ID, ID: b1})
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{a2}, search(&MessageSearchRequest{Query: "runbook"}))
+	require.Equal(t, []string{a1}, search(&MessageSearchRequest{Query: "now"}))
+	require.Equal(t, []string{a2}, postings("runbook"))
+	require.Equal(t, []string{}, postings("link"))
+	require.Equal(t, []string{a1}, postings("deploy"))
+
+	// Leaving removes the channel's messages.
+	_, err = aliceService.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: other.ID})
+	require.NoError(t, err)
+	require.Equal(t, []string{}, search(&MessageSearchRequest{Query: "runbook"}))
+	require.Equal(t, []string{}, postings("runbook"))
+	require.Equal(t, []string{}, postings("old"))
+}
+
+func TestSearchIndexConcurrent(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	a, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "a"})
+	require.NoError(t, err)
+	b, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "b"})
+	require.NoError(t, err)
+	_, err = aliceService.Channels(ctx, &ChannelsRequest{Update: true})
+	require.NoError(t, err)
+	ca, err := keys.ParseID(a.ID)
+	require.NoError(t, err)
+	cb, err := keys.ParseID(b.ID)
+	require.NoError(t, err)
+
+	// Messages with the same term in both channels, and edits in a, which
+	// rewrite the posting for that term.
+	n := 20
+	msgsA := []*api.Message{}
+	msgsB := []*api.Message{}
+	want := []string{}
+	for i := 0; i < n; i++ {
+		msgsA = append(msgsA, &api.Message{ID: fmt.Sprintf("a%d", i), Channel: ca, Sender: alice.ID(), Text: "shared", RemoteIndex: int64(i + 1)})
+		msgsB = append(msgsB, &api.Message{ID: fmt.Sprintf("b%d", i), Channel: cb, Sender: alice.ID(), Text: "shared", RemoteIndex: int64(i + 1)})
+		want = append(want, fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i))
+	}
+	for i := 0; i < n; i++ {
+		msgsA = append(msgsA, &api.Message{ID: fmt.Sprintf("e%d", i), Channel: ca, Sender: alice.ID(), RemoteIndex: int64(n + i + 1), Command: &api.MessageCommand{
+			MessageEdit: &api.MessageEdit{ID: fmt.Sprintf("a%d", i), Text: "shared again"},
+		}})
+	}
+	require.NoError(t, aliceService.messenger.AddMessages(ca, msgsA[:n]))
+	require.NoError(t, aliceService.indexChannel(ctx, ca))
+	require.NoError(t, aliceService.messenger.AddMessages(ca, msgsA[n:]))
+	require.NoError(t, aliceService.messenger.AddMessages(cb, msgsB))
+
+	var wg sync.WaitGroup
+	for _, cid := range []keys.ID{ca, cb} {
+		wg.Add(1)
+		go func(cid keys.ID) {
+			defer wg.Done()
+			require.NoError(t, aliceService.indexChannel(ctx, cid))
+		}(cid)
+	}
+	wg.Wait()
+
+	ids, err := aliceService.searchPostings(ctx, "shared")
+	require.NoError(t, err)
+	out := []string{}
+	for id := range ids {
+		out = append(out, id)
+	}
+	require.ElementsMatch(t, want, out)
+}
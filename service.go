@@ -24,6 +24,8 @@ type service struct {
 	build  Build
 	authIr *authInterceptor
 
+	// db is the (encrypted) service db, for state that's only on this
+	// device, like drafts, the outbox and the search index.
 	db      *sqlcipher.DB
 	client  *client.Client
 	kclient *kclient.Client
@@ -37,6 +39,20 @@ type service struct {
 
 	messenger *messaging.Messenger
 	relay     *relay
+
+	syncMtx sync.Mutex
+	syncing bool
+
+	userDir     *userDirectory
+	aggregates  *aggregateCache
+	unreadLocks *unreadLocks
+	readSyncMtx sync.Mutex
+	searchMtx   sync.Mutex
+
+	outboxSending *outboxSending
+
+	scheduler *scheduler
+	reaper    *scheduler
 }
 
 func newService(
@@ -81,19 +97,29 @@ func newService(
 		return nil, err
 	}
 
-	return &service{
-		authIr:  authIr,
-		build:   build,
-		env:     env,
-		scs:     scs,
-		users:   usrs,
-		db:      db,
-		client:  client,
-		kclient: kclient,
-		keyring: keyring,
-		relay:   relay,
-		clock:   clock,
-	}, nil
+	s := &service{
+		authIr:      authIr,
+		build:       build,
+		env:         env,
+		scs:         scs,
+		users:       usrs,
+		db:          db,
+		client:      client,
+		kclient:     kclient,
+		keyring:     keyring,
+		relay:       relay,
+		clock:       clock,
+		userDir:     newUserDirectory(),
+		aggregates:  newAggregateCache(),
+		unreadLocks: newUnreadLocks(),
+
+		outboxSending: newOutboxSending(),
+
+		scheduler: newScheduler(scheduleInterval),
+		reaper:    newScheduler(reapInterval),
+	}
+	relay.connect = s.relayConnect
+	return s, nil
 }
 
 func (s *service) Close() {
@@ -23,7 +23,7 @@ import (
 var alice = keys.NewEdX25519KeyFromSeed(testutil.Seed(0x01))
 var bob = keys.NewEdX25519KeyFromSeed(testutil.Seed(0x02))
 
-// var charlie = keys.NewEdX25519KeyFromSeed(testutil.Seed(0x03))
+var charlie = keys.NewEdX25519KeyFromSeed(testutil.Seed(0x03))
 var team = keys.NewEdX25519KeyFromSeed(testutil.Seed(0x90))
 
 func newEnv(t *testing.T, appName string, keysPubServerURL string, chillServerURL string) (*Env, CloseFn) {
@@ -245,3 +245,27 @@ func bootstrapInvite(t *testing.T, fi chillserver.Fire, email string) {
 	err := fi.Set(context.TODO(), dstore.Path("account-invites", email), dstore.From(invite))
 	require.NoError(t, err)
 }
+
+// newTestTeamUser returns a service for a user with an account (for key) on
+// the test servers. If inviter is nil, the user creates the team, otherwise
+// they're invited to it by inviter.
+func newTestTeamUser(t *testing.T, env *testServerEnv, email string, key *keys.EdX25519Key, inviter *service) (*serviceEnv, CloseFn) {
+	serviceEnv, closeFn := newTestServiceEnv(t, env)
+	testAuthSetup(t, serviceEnv.service)
+	if inviter == nil {
+		testAccountSetup(t, serviceEnv, email, key)
+		testTeamCreate(t, serviceEnv.service, team)
+		return serviceEnv, closeFn
+	}
+	code := testAccountInvite(t, inviter, email)
+	testAccountSetup(t, serviceEnv, email, key)
+	testAccountInviteAccept(t, serviceEnv.service, code)
+	return serviceEnv, closeFn
+}
+
+func testSetUsername(t *testing.T, service *service, username string) {
+	_, err := service.AccountSetUsername(context.TODO(), &AccountSetUsernameRequest{
+		Username: username,
+	})
+	require.NoError(t, err)
+}
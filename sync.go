@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"github.com/keys-pub/keys"
+	"github.com/pkg/errors"
+)
+
+// startSync keeps the relay connection running in the background while we're
+// unlocked, so messages are pulled (and the outbox sent) even if no UI is
+// connected. It also starts the scheduler for scheduled messages, the reaper
+// for expired messages and user directory refreshes. Sync needs an account
+// and team, so if we don't have them yet, we start when the team is saved.
+func (s *service) startSync() error {
+	s.syncMtx.Lock()
+	defer s.syncMtx.Unlock()
+	if s.syncing {
+		return nil
+	}
+	account, err := s.account(false)
+	if err != nil {
+		return err
+	}
+	team, err := s.team(false)
+	if err != nil {
+		return err
+	}
+	if account == nil || team == nil {
+		logger.Debugf("Not syncing, no account or team")
+		return nil
+	}
+	logger.Infof("Starting sync...")
+	s.syncing = true
+	s.relay.acquire()
+	s.userDir.start()
+	s.scheduler.start(s.sendScheduled)
+	s.reaper.start(s.reapExpired)
+	return nil
+}
+
+// stopSync stops background sync, and waits for it, so it's safe to close the
+// db after. The relay connection stays open if there are UI subscribers, see
+// relay.suspend.
+func (s *service) stopSync() {
+	s.syncMtx.Lock()
+	defer s.syncMtx.Unlock()
+	if !s.syncing {
+		return
+	}
+	logger.Infof("Stopping sync...")
+	s.syncing = false
+	s.scheduler.stop()
+	s.reaper.stop()
+	s.userDir.stop()
+	s.relay.release()
+}
+
+// pullChannels pulls messages for channels in parallel (up to the sync-workers
+// limit), sending progress events. A failed channel doesn't stop the others,
+// the first error is returned.
+func (s *service) pullChannels(ctx context.Context, cids []keys.ID) error {
+	if len(cids) == 0 {
+		return nil
+	}
+	logger.Debugf("Sync %d channel(s)...", len(cids))
+	return syncChannels(ctx, cids, s.env.SyncWorkers(), s.PullMessages, func(cid keys.ID, completed int, err error) {
+		s.relay.Send(relaySyncEvent(cid, completed, len(cids), err))
+	})
+}
+
+// syncChannels runs fn for channels, with at most workers running at the same
+// time. Stops starting new channels if the context is canceled.
+func syncChannels(ctx context.Context, cids []keys.ID, workers int, fn func(ctx context.Context, cid keys.ID) error, progress func(cid keys.ID, completed int, err error)) error {
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	completed := 0
+	var syncErr error
+
+	for _, cid := range cids {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(cid keys.ID) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := fn(ctx, cid)
+			if err != nil {
+				logger.Warningf("Failed to sync channel %s: %v", cid, err)
+			}
+			mtx.Lock()
+			defer mtx.Unlock()
+			completed++
+			if err != nil && syncErr == nil {
+				syncErr = errors.Wrapf(err, "failed to sync channel %s", cid)
+			}
+			progress(cid, completed, err)
+		}(cid)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return syncErr
+}
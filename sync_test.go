@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/keys-pub/keys"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncChannels(t *testing.T) {
+	cids := []keys.ID{}
+	for i := 0; i < 20; i++ {
+		cids = append(cids, keys.ID(fmt.Sprintf("c%d", i)))
+	}
+
+	var mtx sync.Mutex
+	running, maxRunning := 0, 0
+	fn := func(ctx context.Context, cid keys.ID) error {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+		defer func() {
+			mtx.Lock()
+			running--
+			mtx.Unlock()
+		}()
+		if cid == "c5" {
+			return errors.Errorf("pull failed")
+		}
+		return nil
+	}
+	completed := []int{}
+	failed := []keys.ID{}
+	progress := func(cid keys.ID, n int, err error) {
+		completed = append(completed, n)
+		if err != nil {
+			failed = append(failed, cid)
+		}
+	}
+
+	err := syncChannels(context.TODO(), cids, 3, fn, progress)
+	require.EqualError(t, err, "failed to sync channel c5: pull failed")
+	require.True(t, maxRunning <= 3)
+	require.Equal(t, 20, len(completed))
+	for i, n := range completed {
+		require.Equal(t, i+1, n)
+	}
+	require.Equal(t, []keys.ID{"c5"}, failed)
+}
+
+func TestSyncChannelsCancel(t *testing.T) {
+	cids := []keys.ID{"c1", "c2", "c3", "c4"}
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	fn := func(ctx context.Context, cid keys.ID) error {
+		count++
+		cancel()
+		return ctx.Err()
+	}
+	err := syncChannels(ctx, cids, 1, fn, func(keys.ID, int, error) {})
+	require.EqualError(t, err, "context canceled")
+	require.Equal(t, 1, count)
+}
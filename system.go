@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/getchill-app/http/api"
+	"github.com/getchill-app/messaging"
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/encoding"
+)
+
+// systemMessage is a local message in a channel timeline, for command
+// results, errors and notices. It is never sent, and is saved with the
+// channel's messages in messaging.db.
+type systemMessage struct {
+	ID        string
+	Channel   keys.ID
+	Text      []string
+	Timestamp int64
+}
+
+func (m *systemMessage) toRPC() *Message {
+	return &Message{
+		ID:        m.ID,
+		Text:      m.Text,
+		CreatedAt: m.Timestamp,
+		System:    true,
+	}
+}
+
+// newSystemMessage returns a system message, without saving it.
+func (s *service) newSystemMessage(channel keys.ID, text ...string) *systemMessage {
+	return &systemMessage{
+		ID:        encoding.MustEncode(keys.RandBytes(32), encoding.Base62),
+		Channel:   channel,
+		Text:      text,
+		Timestamp: s.clock.NowMillis(),
+	}
+}
+
+// addSystemMessage saves a system message to the channel timeline.
+func (s *service) addSystemMessage(ctx context.Context, channel keys.ID, text ...string) (*Message, error) {
+	sm := s.newSystemMessage(channel, text...)
+	logger.Debugf("System message %s for %s", sm.ID, channel)
+	if err := s.messenger.AddSystemMessage(&messaging.SystemMessage{
+		ID:        sm.ID,
+		Channel:   sm.Channel,
+		Text:      sm.Text,
+		Timestamp: sm.Timestamp,
+	}); err != nil {
+		return nil, err
+	}
+	m := sm.toRPC()
+	s.relay.Send(&RelayOutput{Event: &RelayOutput_Channel{Channel: &RelayChannel{
+		ID:       channel.String(),
+		Messages: []*Message{m},
+	}}})
+	return m, nil
+}
+
+// systemMessages returns system messages for a channel, ordered by timestamp.
+func (s *service) systemMessages(channel keys.ID) ([]*systemMessage, error) {
+	sms, err := s.messenger.SystemMessages(channel)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*systemMessage, 0, len(sms))
+	for _, sm := range sms {
+		out = append(out, &systemMessage{
+			ID:        sm.ID,
+			Channel:   sm.Channel,
+			Text:      sm.Text,
+			Timestamp: sm.Timestamp,
+		})
+	}
+	return out, nil
+}
+
+// pageTimeRange returns the time range (inclusive) of system messages to
+// include with a page of messages (in page order). A page covers from the
+// message it was listed from (exclusive), which is the last message of the
+// previous page, to its own last message, so adjacent pages don't leave a gap.
+// The first page covers from the start (prev is nil) and the last page (no
+// more) covers to the end.
+func pageTimeRange(msgs []*Message, descending bool, prev *api.Message, more bool) (int64, int64) {
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+	var last int64
+	if len(msgs) > 0 {
+		last = msgs[len(msgs)-1].CreatedAt
+	}
+	if descending {
+		if prev != nil {
+			to = prev.Timestamp - 1
+		}
+		if more {
+			from = last
+		}
+	} else {
+		if prev != nil {
+			from = prev.Timestamp + 1
+		}
+		if more {
+			to = last
+		}
+	}
+	return from, to
+}
+
+// withSystemMessages merges system messages with timestamps from, to
+// (inclusive) into a page of messages, by timestamp.
+func withSystemMessages(msgs []*Message, sms []*systemMessage, descending bool, from int64, to int64) []*Message {
+	if len(sms) == 0 {
+		return msgs
+	}
+
+	asc := make([]*Message, len(msgs))
+	copy(asc, msgs)
+	if descending {
+		reverseMessages(asc)
+	}
+	out := make([]*Message, 0, len(msgs)+len(sms))
+	i := 0
+	for _, sm := range sms {
+		if sm.Timestamp < from || sm.Timestamp > to {
+			continue
+		}
+		for i < len(asc) && asc[i].CreatedAt <= sm.Timestamp {
+			out = append(out, asc[i])
+			i++
+		}
+		out = append(out, sm.toRPC())
+	}
+	out = append(out, asc[i:]...)
+	if descending {
+		reverseMessages(out)
+	}
+	return out
+}
+
+func reverseMessages(msgs []*Message) {
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/getchill-app/http/api"
+	"github.com/keys-pub/keys"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithSystemMessages(t *testing.T) {
+	msgs := []*Message{{ID: "m1", CreatedAt: 10}, {ID: "m2", CreatedAt: 20}, {ID: "m3", CreatedAt: 30}}
+	sms := []*systemMessage{
+		{ID: "s1", Text: []string{"before"}, Timestamp: 5},
+		{ID: "s2", Text: []string{"between"}, Timestamp: 25},
+		{ID: "s3", Text: []string{"after"}, Timestamp: 35},
+	}
+	ids := func(msgs []*Message) []string {
+		out := []string{}
+		for _, m := range msgs {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	all := func(msgs []*Message, descending bool, prev *api.Message, more bool) []string {
+		from, to := pageTimeRange(msgs, descending, prev, more)
+		return ids(withSystemMessages(msgs, sms, descending, from, to))
+	}
+
+	out := withSystemMessages(msgs, sms, false, math.MinInt64, math.MaxInt64)
+	require.Equal(t, []string{"s1", "m1", "m2", "s2", "m3", "s3"}, ids(out))
+	require.True(t, out[0].System)
+	require.False(t, out[1].System)
+
+	// Middle page
+	require.Equal(t, []string{"m1", "m2", "s2", "m3"}, all(msgs, false, &api.Message{Timestamp: 5}, true))
+
+	// Latest page, descending
+	desc := []*Message{msgs[2], msgs[1], msgs[0]}
+	require.Equal(t, []string{"s3", "m3", "s2", "m2", "m1"}, all(desc, true, nil, true))
+
+	// Empty
+	require.Equal(t, []string{"s1", "s2", "s3"}, all([]*Message{}, false, nil, false))
+	require.Equal(t, []string{"s3"}, all([]*Message{}, false, &api.Message{Timestamp: 30}, false))
+	require.Equal(t, []string{}, ids(withSystemMessages([]*Message{}, sms, false, 31, 34)))
+}
+
+func TestWithSystemMessagesPaged(t *testing.T) {
+	m1, m2 := &Message{ID: "m1", CreatedAt: 10}, &Message{ID: "m2", CreatedAt: 20}
+	m3, m4 := &Message{ID: "m3", CreatedAt: 30}, &Message{ID: "m4", CreatedAt: 40}
+	sms := []*systemMessage{
+		{ID: "s1", Text: []string{"before"}, Timestamp: 5},
+		{ID: "s2", Text: []string{"between pages"}, Timestamp: 25},
+		{ID: "s3", Text: []string{"after"}, Timestamp: 45},
+	}
+	page := func(msgs []*Message, descending bool, prev *api.Message, more bool) []string {
+		from, to := pageTimeRange(msgs, descending, prev, more)
+		out := []string{}
+		for _, m := range withSystemMessages(msgs, sms, descending, from, to) {
+			out = append(out, m.ID)
+		}
+		return out
+	}
+
+	// Ascending, 2 per page
+	require.Equal(t, []string{"s1", "m1", "m2"}, page([]*Message{m1, m2}, false, nil, true))
+	require.Equal(t, []string{"s2", "m3", "m4", "s3"}, page([]*Message{m3, m4}, false, &api.Message{Timestamp: 20}, false))
+
+	// Descending, 2 per page
+	require.Equal(t, []string{"s3", "m4", "m3"}, page([]*Message{m4, m3}, true, nil, true))
+	require.Equal(t, []string{"s2", "m2", "m1", "s1"}, page([]*Message{m2, m1}, true, &api.Message{Timestamp: 30}, false))
+}
+
+func TestSystemMessages(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+
+	channel, err := aliceService.ChannelCreate(ctx, &ChannelCreateRequest{Name: "testing"})
+	require.NoError(t, err)
+	cid, err := keys.ParseID(channel.ID)
+	require.NoError(t, err)
+
+	_, err = aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "hi"})
+	require.NoError(t, err)
+	send, err := aliceService.MessageSend(ctx, &MessageSendRequest{Channel: channel.ID, Text: "/unknown"})
+	require.NoError(t, err)
+	require.True(t, send.Message.System)
+
+	msgs, err := aliceService.Messages(ctx, &MessagesRequest{Channel: channel.ID, Update: true})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(msgs.Messages))
+	require.Equal(t, []string{"hi"}, msgs.Messages[0].Text)
+	require.Equal(t, []string{"unrecognized command /unknown (see /help)"}, msgs.Messages[1].Text)
+	require.True(t, msgs.Messages[1].System)
+
+	// Saved in messaging.db
+	sms, err := aliceService.systemMessages(cid)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(sms))
+	require.Equal(t, send.Message.ID, sms[0].ID)
+
+	_, err = aliceService.ChannelLeave(ctx, &ChannelLeaveRequest{Channel: channel.ID})
+	require.NoError(t, err)
+	sms, err = aliceService.systemMessages(cid)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(sms))
+}
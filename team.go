@@ -48,7 +48,7 @@ func (s *service) saveTeam(team *keys.EdX25519Key) error {
 	if err := s.keyring.Set(teamKey); err != nil {
 		return err
 	}
-	return nil
+	return s.startSync()
 }
 
 func (s *service) team(required bool) (*kapi.Key, error) {
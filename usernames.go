@@ -2,37 +2,390 @@ package service
 
 import (
 	"context"
+	"sort"
+	"sync"
+	"time"
 
-	"github.com/getchill-app/http/api"
 	"github.com/keys-pub/keys"
 	"github.com/keys-pub/keys/dstore"
+	"github.com/keys-pub/keys/tsutil"
 )
 
+// User directory TTLs. Users we found are refreshed (in the background) after
+// userTTL, users that weren't found are looked up again after userMissingTTL.
+const userTTL = 24 * time.Hour
+const userMissingTTL = time.Hour
+
+// userFailedTTL is how long we wait to look up a user again after the lookup
+// failed (we're offline), so listing messages doesn't retry for each message.
+const userFailedTTL = time.Minute
+
+// userLookupBatchSize is the max number of users to lookup in a request.
+const userLookupBatchSize = 100
+
+// cachedUser is a user directory entry, saved in the service db at
+// /ausers/{kid}.
+type cachedUser struct {
+	KID      keys.ID `json:"kid"`
+	Username string  `json:"username,omitempty"`
+	// Missing if the lookup didn't find the user.
+	Missing bool `json:"missing,omitempty"`
+	// Timestamp is when we looked up the user.
+	Timestamp int64 `json:"ts,omitempty"`
+}
+
+// userDirectory caches users in memory (in front of the service db), and
+// runs the background refreshes, which only happen while we're syncing.
+type userDirectory struct {
+	sync.Mutex
+	users      map[keys.ID]*cachedUser
+	refreshing map[keys.ID]bool
+	// failed is when lookups failed, for users we don't have.
+	failed map[keys.ID]time.Time
+
+	// ctx is canceled on stop, nil if stopped.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newUserDirectory() *userDirectory {
+	return &userDirectory{
+		users:      map[keys.ID]*cachedUser{},
+		refreshing: map[keys.ID]bool{},
+		failed:     map[keys.ID]time.Time{},
+	}
+}
+
+func (d *userDirectory) get(kid keys.ID) *cachedUser {
+	d.Lock()
+	defer d.Unlock()
+	return d.users[kid]
+}
+
+func (d *userDirectory) set(user *cachedUser) {
+	d.Lock()
+	defer d.Unlock()
+	d.users[user.KID] = user
+	delete(d.failed, user.KID)
+}
+
+// setFailed remembers that a lookup for kids failed at now.
+func (d *userDirectory) setFailed(kids []keys.ID, now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+	for _, kid := range kids {
+		d.failed[kid] = now
+	}
+}
+
+// failedRecently returns true if a lookup for kid failed within
+// userFailedTTL.
+func (d *userDirectory) failedRecently(kid keys.ID, now time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+	failed, ok := d.failed[kid]
+	return ok && now.Sub(failed) < userFailedTTL
+}
+
+// clear the memory cache, on lock.
+func (d *userDirectory) clear() {
+	d.Lock()
+	defer d.Unlock()
+	d.users = map[keys.ID]*cachedUser{}
+	d.failed = map[keys.ID]time.Time{}
+}
+
+// start allows background refreshes, on startSync.
+func (d *userDirectory) start() {
+	d.Lock()
+	defer d.Unlock()
+	if d.cancel != nil {
+		return
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+}
+
+// stop cancels background refreshes and waits for them, on stopSync.
+func (d *userDirectory) stop() {
+	d.Lock()
+	cancel := d.cancel
+	d.ctx, d.cancel = nil, nil
+	d.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	d.wg.Wait()
+}
+
+// refresh runs fn in the background for the kids that aren't already being
+// refreshed. Does nothing if stopped.
+func (d *userDirectory) refresh(kids []keys.ID, fn func(ctx context.Context, kids []keys.ID)) {
+	d.Lock()
+	defer d.Unlock()
+	if d.ctx == nil {
+		return
+	}
+	refresh := d.startRefresh(kids)
+	if len(refresh) == 0 {
+		return
+	}
+	ctx := d.ctx
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		defer d.endRefresh(refresh)
+		fn(ctx, refresh)
+	}()
+}
+
+// startRefresh returns the kids that aren't already being refreshed, and marks
+// them as refreshing. Caller should hold the lock.
+func (d *userDirectory) startRefresh(kids []keys.ID) []keys.ID {
+	out := []keys.ID{}
+	for _, kid := range kids {
+		if d.refreshing[kid] {
+			continue
+		}
+		d.refreshing[kid] = true
+		out = append(out, kid)
+	}
+	return out
+}
+
+func (d *userDirectory) endRefresh(kids []keys.ID) {
+	d.Lock()
+	defer d.Unlock()
+	for _, kid := range kids {
+		delete(d.refreshing, kid)
+	}
+}
+
+// stale returns true if the user should be looked up again.
+func (u *cachedUser) stale(now time.Time) bool {
+	ttl := userTTL
+	if u.Missing {
+		ttl = userMissingTTL
+	}
+	return now.Sub(tsutil.ConvertMillis(u.Timestamp)) > ttl
+}
+
 func (s *service) userName(ctx context.Context, kid keys.ID) (string, error) {
-	path := dstore.Path("ausers", kid)
-	doc, err := s.db.Get(ctx, path)
+	users, err := s.lookupUsers(ctx, []keys.ID{kid})
 	if err != nil {
 		return "", err
 	}
-	if doc == nil {
-		account, err := s.account(true)
+	return users[kid].Username, nil
+}
+
+// userNames returns usernames for kids, resolving them as a batch.
+// If a user isn't found, the username is empty.
+func (s *service) userNames(ctx context.Context, kids []keys.ID) (map[keys.ID]string, error) {
+	users, err := s.lookupUsers(ctx, kids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[keys.ID]string, len(users))
+	for kid, user := range users {
+		out[kid] = user.Username
+	}
+	return out, nil
+}
+
+// lookupUsers returns users from the directory, looking up users we haven't
+// seen. Stale users are returned from the cache and refreshed in the
+// background. Every kid has an entry in the result.
+func (s *service) lookupUsers(ctx context.Context, kids []keys.ID) (map[keys.ID]*cachedUser, error) {
+	now := s.clock.Now()
+	out := map[keys.ID]*cachedUser{}
+	misses := []keys.ID{}
+	stale := []keys.ID{}
+	for _, kid := range kids {
+		if _, ok := out[kid]; ok {
+			continue
+		}
+		user, err := s.cachedUser(ctx, kid)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+		if user == nil {
+			out[kid] = &cachedUser{KID: kid}
+			if !s.userDir.failedRecently(kid, now) {
+				misses = append(misses, kid)
+			}
+			continue
+		}
+		out[kid] = user
+		if user.stale(now) {
+			stale = append(stale, kid)
 		}
-		usr, err := s.client.UserLookup(ctx, "kid", kid.String(), account.AsEdX25519())
+	}
+
+	if len(misses) > 0 {
+		found, err := s.fetchUsers(ctx, misses)
 		if err != nil {
-			return "", nil
+			return nil, err
+		}
+		check := []keys.ID{}
+		for _, user := range found {
+			out[user.KID] = user
+			check = append(check, user.KID)
+		}
+		s.userDir.refresh(check, s.checkIdentities)
+	}
+
+	s.userDir.refresh(stale, s.refreshUsers)
+
+	return out, nil
+}
+
+// refreshUsers looks up stale users again, and checks their sigchains.
+func (s *service) refreshUsers(ctx context.Context, kids []keys.ID) {
+	logger.Debugf("Refreshing %d user(s)", len(kids))
+	users, err := s.fetchUsers(ctx, kids)
+	if err != nil {
+		logger.Warningf("Failed to refresh users: %v", err)
+		return
+	}
+	found := []keys.ID{}
+	for _, user := range users {
+		if !user.Missing {
+			found = append(found, user.KID)
+		}
+	}
+	s.checkIdentities(ctx, found)
+}
+
+// checkIdentities updates identities we haven't checked recently, so we can
+// warn if their sigchain changed. These are one at a time, in the background.
+func (s *service) checkIdentities(ctx context.Context, kids []keys.ID) {
+	for _, kid := range kids {
+		if ctx.Err() != nil {
+			return
 		}
-		if usr != nil {
-			if err := s.db.Set(ctx, path, dstore.From(usr)); err != nil {
-				return "", nil
+		rec, err := s.identityRecord(ctx, kid)
+		if err != nil {
+			logger.Warningf("Failed to load identity %s: %v", kid, err)
+			continue
+		}
+		if rec != nil && s.clock.Now().Sub(tsutil.ConvertMillis(rec.CheckedAt)) < userTTL {
+			continue
+		}
+		if err := s.updateIdentity(ctx, kid); err != nil {
+			logger.Warningf("Failed to update identity %s: %v", kid, err)
+		}
+	}
+}
+
+func (s *service) cachedUser(ctx context.Context, kid keys.ID) (*cachedUser, error) {
+	if user := s.userDir.get(kid); user != nil {
+		return user, nil
+	}
+	var user cachedUser
+	ok, err := s.db.Load(ctx, dstore.Path("ausers", kid), &user)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	s.userDir.set(&user)
+	return &user, nil
+}
+
+// fetchUsers looks up users (in batches) and saves the results to the
+// directory, including users that weren't found. If a lookup fails (for
+// example, we're offline), we don't cache anything for those users, and only
+// remember that it failed, see userFailedTTL.
+func (s *service) fetchUsers(ctx context.Context, kids []keys.ID) ([]*cachedUser, error) {
+	account, err := s.account(true)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*cachedUser{}
+	for len(kids) > 0 {
+		batch := kids
+		if len(batch) > userLookupBatchSize {
+			batch = batch[:userLookupBatchSize]
+		}
+		kids = kids[len(batch):]
+
+		usrs, err := s.client.UsersLookup(ctx, batch, account.AsEdX25519())
+		if err != nil {
+			logger.Warningf("Failed to lookup %d user(s): %v", len(batch), err)
+			s.userDir.setFailed(batch, s.clock.Now())
+			continue
+		}
+		found := map[keys.ID]string{}
+		for _, usr := range usrs {
+			found[usr.KID] = usr.Username
+		}
+		ts := s.clock.NowMillis()
+		for _, kid := range batch {
+			user := &cachedUser{KID: kid, Timestamp: ts}
+			if username, ok := found[kid]; ok {
+				user.Username = username
+			} else {
+				user.Missing = true
+			}
+			if err := s.db.Set(ctx, dstore.Path("ausers", kid), dstore.From(user)); err != nil {
+				return nil, err
 			}
+			s.userDir.set(user)
+			out = append(out, user)
 		}
-		return usr.Username, nil
 	}
-	var usr api.User
-	if err := doc.To(&usr); err != nil {
-		return "", err
+	return out, nil
+}
+
+// Users (RPC) returns users from the user directory. If no kids are
+// specified, returns all users in the directory.
+func (s *service) Users(ctx context.Context, req *UsersRequest) (*UsersResponse, error) {
+	var users []*cachedUser
+	if len(req.KIDs) == 0 {
+		docs, err := s.db.Documents(ctx, dstore.Path("ausers"))
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			var user cachedUser
+			if err := doc.To(&user); err != nil {
+				return nil, err
+			}
+			users = append(users, &user)
+		}
+	} else {
+		kids := make([]keys.ID, 0, len(req.KIDs))
+		for _, k := range req.KIDs {
+			kid, err := keys.ParseID(k)
+			if err != nil {
+				return nil, err
+			}
+			kids = append(kids, kid)
+		}
+		found, err := s.lookupUsers(ctx, kids)
+		if err != nil {
+			return nil, err
+		}
+		for _, kid := range kids {
+			users = append(users, found[kid])
+		}
+	}
+
+	out := make([]*User, 0, len(users))
+	for _, user := range users {
+		u, err := s.userToRPC(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	if len(req.KIDs) == 0 {
+		sort.Slice(out, func(i, j int) bool {
+			return out[i].Username < out[j].Username
+		})
 	}
-	return usr.Username, nil
+	return &UsersResponse{Users: out}, nil
 }
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/keys-pub/keys"
+	"github.com/keys-pub/keys/tsutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedUserStale(t *testing.T) {
+	now := time.Now()
+	found := &cachedUser{KID: "kid1", Username: "alice", Timestamp: tsutil.Millis(now.Add(-time.Hour * 2))}
+	require.False(t, found.stale(now))
+	require.True(t, found.stale(now.Add(userTTL)))
+
+	missing := &cachedUser{KID: "kid2", Missing: true, Timestamp: tsutil.Millis(now.Add(-time.Hour * 2))}
+	require.True(t, missing.stale(now))
+
+	// Entries from before the directory had timestamps are stale
+	legacy := &cachedUser{KID: "kid3", Username: "bob"}
+	require.True(t, legacy.stale(now))
+}
+
+func TestUserDirectoryRefresh(t *testing.T) {
+	d := newUserDirectory()
+	refreshed := make(chan []keys.ID, 10)
+	fn := func(ctx context.Context, kids []keys.ID) {
+		refreshed <- kids
+		<-ctx.Done()
+	}
+
+	// Not refreshing until started
+	d.refresh([]keys.ID{"kid1"}, fn)
+	d.start()
+	d.refresh([]keys.ID{"kid1", "kid2"}, fn)
+	require.Equal(t, []keys.ID{"kid1", "kid2"}, <-refreshed)
+	d.refresh([]keys.ID{"kid1", "kid3"}, fn)
+	require.Equal(t, []keys.ID{"kid3"}, <-refreshed)
+
+	// Stop cancels and waits
+	d.stop()
+	require.Equal(t, 0, len(refreshed))
+	require.Equal(t, 0, len(d.refreshing))
+
+	d.set(&cachedUser{KID: "kid1", Username: "alice"})
+	require.Equal(t, "alice", d.get("kid1").Username)
+	d.clear()
+	require.Nil(t, d.get("kid1"))
+}
+
+func TestUserDirectoryFailed(t *testing.T) {
+	d := newUserDirectory()
+	now := time.Now()
+	require.False(t, d.failedRecently("kid1", now))
+
+	d.setFailed([]keys.ID{"kid1", "kid2"}, now)
+	require.True(t, d.failedRecently("kid1", now.Add(time.Second)))
+	require.False(t, d.failedRecently("kid1", now.Add(userFailedTTL)))
+
+	// Found later
+	d.set(&cachedUser{KID: "kid2", Username: "bob"})
+	require.False(t, d.failedRecently("kid2", now))
+}
+
+func TestUsers(t *testing.T) {
+	env := newTestServerEnv(t)
+	ctx := context.TODO()
+	clock := env.clock.(*tsutil.TestClock)
+
+	aliceServiceEnv, aliceCloseFn := newTestTeamUser(t, env, "alice@keys.pub", alice, nil)
+	defer aliceCloseFn()
+	aliceService := aliceServiceEnv.service
+	testSetUsername(t, aliceService, "alice")
+
+	bobServiceEnv, bobCloseFn := newTestTeamUser(t, env, "bob@keys.pub", bob, aliceService)
+	defer bobCloseFn()
+	bobService := bobServiceEnv.service
+	testSetUsername(t, bobService, "bob")
+
+	// Lookup (bob found, charlie missing)
+	users, err := aliceService.lookupUsers(ctx, []keys.ID{bob.ID(), charlie.ID(), bob.ID()})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(users))
+	require.Equal(t, "bob", users[bob.ID()].Username)
+	require.True(t, users[charlie.ID()].Missing)
+
+	// Saved to the directory
+	resp, err := aliceService.Users(ctx, &UsersRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(resp.Users))
+	require.Equal(t, "", resp.Users[0].Username)
+	require.True(t, resp.Users[0].Missing)
+	require.Equal(t, "bob", resp.Users[1].Username)
+
+	resp, err = aliceService.Users(ctx, &UsersRequest{KIDs: []string{bob.ID().String()}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Users))
+	require.Equal(t, "bob", resp.Users[0].Username)
+	updatedAt := resp.Users[0].UpdatedAt
+
+	// Bob changes his username
+	testSetUsername(t, bobService, "bobby")
+
+	// Cached until stale
+	name, err := aliceService.userName(ctx, bob.ID())
+	require.NoError(t, err)
+	require.Equal(t, "bob", name)
+
+	// Stale is returned, and refreshed in the background
+	clock.Add(userTTL + time.Second)
+	name, err = aliceService.userName(ctx, bob.ID())
+	require.NoError(t, err)
+	require.Equal(t, "bob", name)
+	aliceService.userDir.wg.Wait()
+
+	resp, err = aliceService.Users(ctx, &UsersRequest{KIDs: []string{bob.ID().String()}})
+	require.NoError(t, err)
+	require.Equal(t, "bobby", resp.Users[0].Username)
+	require.True(t, resp.Users[0].UpdatedAt > updatedAt)
+
+	// Missing users are looked up again after a while
+	clock.Add(userMissingTTL + time.Second)
+	_, err = aliceService.lookupUsers(ctx, []keys.ID{charlie.ID()})
+	require.NoError(t, err)
+	aliceService.userDir.wg.Wait()
+	users, err = aliceService.lookupUsers(ctx, []keys.ID{charlie.ID()})
+	require.NoError(t, err)
+	require.True(t, users[charlie.ID()].Missing)
+	require.True(t, users[charlie.ID()].Timestamp > resp.Users[0].UpdatedAt)
+
+	// No refreshes after lock
+	_, err = aliceService.AuthLock(ctx, &AuthLockRequest{})
+	require.NoError(t, err)
+	aliceService.userDir.Lock()
+	require.Nil(t, aliceService.userDir.ctx)
+	aliceService.userDir.Unlock()
+}